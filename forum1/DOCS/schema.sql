@@ -6,14 +6,36 @@ CREATE TABLE IF NOT EXISTS user (
         CHECK (username GLOB '[a-zA-Z0-9_]*')
         CHECK (username NOT GLOB '*[^a-zA-Z0-9_]*'),
     email TEXT NOT NULL UNIQUE,
-    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    username_canonical TEXT, -- case-folded, NFKC normalised username
+    email_canonical TEXT, -- case-folded, NFKC normalised email
+    role TEXT NOT NULL DEFAULT 'user'
+        CHECK (role IN ('user', 'moderator', 'admin')),
+    status TEXT NOT NULL DEFAULT 'active' -- pending accounts wait for admin approval
+        CHECK (status IN ('active', 'pending'))
 );
 
+-- Created by the startup migration once no collisions exist
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_username_canonical ON user(username_canonical);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_canonical ON user(email_canonical);
+
 -- User authentication table
 CREATE TABLE IF NOT EXISTS user_auth (
     user_id TEXT PRIMARY KEY,
     password_hash TEXT NOT NULL 
-    CHECK (length(password_hash) = 60),
+    CHECK (password_hash LIKE '$2_$%' OR password_hash LIKE '$argon2id$%'),
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- User profile table (one-to-one with user)
+CREATE TABLE IF NOT EXISTS user_profile (
+    user_id TEXT PRIMARY KEY,
+    display_name TEXT NOT NULL DEFAULT '',
+    bio TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT '',
+    website TEXT NOT NULL DEFAULT '',
+    avatar_url TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
 );
 
@@ -27,6 +49,22 @@ CREATE TABLE sessions (
     FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
 );
 
+-- User sanctions table (warnings, suspensions and bans)
+CREATE TABLE IF NOT EXISTS user_sanctions (
+    sanction_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    moderator_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000', -- placeholder user once the moderator is deleted
+    sanction_type TEXT NOT NULL
+        CHECK (sanction_type IN ('warning', 'suspension', 'ban')),
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP, -- only set for suspensions
+    revoked_at TIMESTAMP,
+    revoked_by TEXT,
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (moderator_id) REFERENCES user(user_id) ON DELETE SET DEFAULT
+);
+
 -- Categories table
 CREATE TABLE categories (
     category_id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -41,6 +79,11 @@ CREATE TABLE posts (
     content TEXT NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP,
+    deleted_at TIMESTAMP, -- soft deleted, hard deleted once the trash retention period is over
+    deleted_by TEXT REFERENCES user(user_id) ON DELETE SET NULL,
+    delete_reason TEXT NOT NULL DEFAULT '',
+    draft INTEGER NOT NULL DEFAULT 0 CHECK (draft IN (0, 1)), -- drafts are only visible to their author
+    publish_at TIMESTAMP, -- scheduled publication time of a draft
     FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
     FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE CASCADE
 );
@@ -53,6 +96,10 @@ CREATE TABLE comments (
     content TEXT NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP,
+    deleted_at TIMESTAMP, -- soft deleted, hard deleted once the trash retention period is over
+    deleted_by TEXT REFERENCES user(user_id) ON DELETE SET NULL,
+    delete_reason TEXT NOT NULL DEFAULT '',
+    reply_to TEXT REFERENCES comments(comment_id) ON DELETE SET NULL, -- comment this one answers, if any
     FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
     FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
 );
@@ -71,6 +118,232 @@ CREATE TABLE IF NOT EXISTS reactions (
     CHECK ((post_id IS NULL AND comment_id IS NOT NULL) OR (post_id IS NOT NULL AND comment_id IS NULL))
 );
 
+-- Invite codes table (invite-only registration)
+CREATE TABLE IF NOT EXISTS invite_codes (
+    code TEXT PRIMARY KEY,
+    created_by TEXT NOT NULL,
+    max_uses INTEGER NOT NULL CHECK (max_uses > 0),
+    uses INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES user(user_id) ON DELETE CASCADE,
+    CHECK (uses <= max_uses)
+);
+
+-- Email domain rules table (runtime allow/deny lists managed by admins)
+CREATE TABLE IF NOT EXISTS email_domain_rules (
+    domain TEXT PRIMARY KEY,
+    rule_type TEXT NOT NULL CHECK (rule_type IN ('allow', 'deny')),
+    created_by TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES user(user_id) ON DELETE SET NULL
+);
+
+-- User blocks table (blocked users cannot reply to, react to, mention or message the blocker)
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id TEXT NOT NULL,
+    blocked_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    CHECK (blocker_id != blocked_id)
+);
+
+-- User mutes table (muted users' content is hidden from the muter)
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id TEXT NOT NULL,
+    muted_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    CHECK (muter_id != muted_id)
+);
+
+-- Security events table (per-user log of logins, failed attempts and account changes)
+CREATE TABLE IF NOT EXISTS security_events (
+    event_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- User devices table (device fingerprints seen at login, for new-device alerts)
+CREATE TABLE IF NOT EXISTS user_devices (
+    user_id TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, fingerprint),
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- Impersonation sessions table (audit log of admins viewing the forum as another user)
+CREATE TABLE IF NOT EXISTS impersonation_sessions (
+    impersonation_id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL UNIQUE,
+    admin_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000', -- placeholder user once the admin is deleted
+    target_user_id TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    FOREIGN KEY (admin_id) REFERENCES user(user_id) ON DELETE SET DEFAULT,
+    FOREIGN KEY (target_user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- Uploads table (images, stored once per distinct file by SHA-256)
+CREATE TABLE IF NOT EXISTS uploads (
+    upload_id TEXT PRIMARY KEY,
+    sha256 TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    path TEXT NOT NULL,
+    thumbnail_path TEXT NOT NULL,
+    thumbnail_type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- User uploads table (who uploaded each image; only they may attach it)
+CREATE TABLE IF NOT EXISTS user_uploads (
+    user_id TEXT NOT NULL,
+    upload_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, upload_id),
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (upload_id) REFERENCES uploads(upload_id) ON DELETE CASCADE
+);
+
+-- Attachments table (images attached to exactly one post or comment)
+CREATE TABLE IF NOT EXISTS attachments (
+    attachment_id TEXT PRIMARY KEY,
+    upload_id TEXT NOT NULL,
+    post_id TEXT,
+    comment_id TEXT,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (upload_id) REFERENCES uploads(upload_id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    CHECK ((post_id IS NULL) != (comment_id IS NULL))
+);
+
+-- Content revisions table (every version of edited posts and comments; revision 1 is the original)
+CREATE TABLE IF NOT EXISTS content_revisions (
+    revision_id TEXT PRIMARY KEY,
+    post_id TEXT,
+    comment_id TEXT,
+    revision_number INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    editor_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000', -- placeholder user once the editor is deleted
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES user(user_id) ON DELETE SET DEFAULT,
+    CHECK ((post_id IS NULL) != (comment_id IS NULL))
+);
+
+-- Notifications table (replies, reactions, mentions and moderator actions)
+CREATE TABLE IF NOT EXISTS notifications (
+    notification_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL, -- recipient
+    actor_id TEXT, -- user who caused it; moderators are not shown to the recipient
+    notification_type TEXT NOT NULL
+        CHECK (notification_type IN ('reply_to_post', 'reply_to_comment', 'reaction', 'mention', 'moderation')),
+    post_id TEXT,
+    comment_id TEXT,
+    reaction_type INTEGER, -- 1 for like, 2 for dislike
+    message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES user(user_id) ON DELETE SET NULL,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE
+);
+
+-- Notification preferences table (missing rows mean enabled)
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id TEXT NOT NULL,
+    notification_type TEXT NOT NULL,
+    enabled INTEGER NOT NULL CHECK (enabled IN (0, 1)),
+    PRIMARY KEY (user_id, notification_type),
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- Mentions table (users @mentioned in a post or comment)
+CREATE TABLE IF NOT EXISTS mentions (
+    user_id TEXT NOT NULL,
+    post_id TEXT,
+    comment_id TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    CHECK ((post_id IS NULL AND comment_id IS NOT NULL) OR (post_id IS NOT NULL AND comment_id IS NULL))
+);
+
+-- Conversations table (private one-to-one and group messaging)
+CREATE TABLE IF NOT EXISTS conversations (
+    conversation_id TEXT PRIMARY KEY,
+    title TEXT NOT NULL DEFAULT '', -- group conversations only
+    direct_key TEXT UNIQUE, -- both user IDs, sorted, for one-to-one conversations
+    created_by TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_message_at TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES user(user_id) ON DELETE SET NULL
+);
+
+-- Conversation members table
+CREATE TABLE IF NOT EXISTS conversation_members (
+    conversation_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_read_at TIMESTAMP, -- everything sent up to then has been read
+    muted INTEGER NOT NULL DEFAULT 0 CHECK (muted IN (0, 1)),
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- Messages table
+CREATE TABLE IF NOT EXISTS messages (
+    message_id TEXT PRIMARY KEY,
+    conversation_id TEXT NOT NULL,
+    sender_id TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
+
+-- Bookmarks table (posts and comments saved by a user)
+CREATE TABLE IF NOT EXISTS bookmarks (
+    bookmark_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    post_id TEXT,
+    comment_id TEXT,
+    folder TEXT NOT NULL DEFAULT '', -- '' when not filed in a folder
+    note TEXT NOT NULL DEFAULT '', -- private to the user
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    CHECK ((post_id IS NULL AND comment_id IS NOT NULL) OR (post_id IS NOT NULL AND comment_id IS NULL))
+);
+
 -- Create necessary indexes
 CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts(user_id);,
 CREATE INDEX IF NOT EXISTS idx_posts_category_id ON posts(category_id);,
@@ -80,5 +353,38 @@ CREATE INDEX IF NOT EXISTS idx_reactions_user_id ON reactions(user_id);,
 CREATE INDEX IF NOT EXISTS idx_reactions_post_id ON reactions(post_id);,
 CREATE INDEX IF NOT EXISTS idx_reactions_comment_id ON reactions(comment_id);,
 CREATE INDEX IF NOT EXISTS idx_sessions_session_id ON sessions(session_id);,
+CREATE INDEX IF NOT EXISTS idx_user_sanctions_user_id ON user_sanctions(user_id, sanction_type);,
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks(blocked_id);,
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at);,
+CREATE INDEX IF NOT EXISTS idx_invite_codes_created_by ON invite_codes(created_by);,
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id, created_at);,
+CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_admin_id ON impersonation_sessions(admin_id);,
+CREATE INDEX IF NOT EXISTS idx_user_uploads_upload_id ON user_uploads(upload_id);,
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments(post_id);,
+CREATE INDEX IF NOT EXISTS idx_attachments_comment_id ON attachments(comment_id);,
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at) WHERE deleted_at IS NOT NULL;,
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at) WHERE deleted_at IS NOT NULL;,
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts(publish_at) WHERE draft = 1 AND publish_at IS NOT NULL;,
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);,
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;,
+CREATE INDEX IF NOT EXISTS idx_notifications_actor_id ON notifications(actor_id);,
+CREATE INDEX IF NOT EXISTS idx_notifications_post_id ON notifications(post_id);,
+CREATE INDEX IF NOT EXISTS idx_notifications_comment_id ON notifications(comment_id);,
+CREATE INDEX IF NOT EXISTS idx_comments_reply_to ON comments(reply_to);,
+CREATE INDEX IF NOT EXISTS idx_content_revisions_editor_id ON content_revisions(editor_id);,
 CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_user_post ON reactions(user_id, post_id) WHERE post_id IS NOT NULL;,
-CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_user_comment ON reactions(user_id, comment_id) WHERE comment_id IS NOT NULL;,
\ No newline at end of file
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_user_comment ON reactions(user_id, comment_id) WHERE comment_id IS NOT NULL;,
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_revisions_post ON content_revisions(post_id, revision_number) WHERE post_id IS NOT NULL;,
+CREATE UNIQUE INDEX IF NOT EXISTS idx_content_revisions_comment ON content_revisions(comment_id, revision_number) WHERE comment_id IS NOT NULL;,
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions(user_id);,
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_post ON mentions(post_id, user_id) WHERE post_id IS NOT NULL;,
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_comment ON mentions(comment_id, user_id) WHERE comment_id IS NOT NULL;,
+CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members(user_id);,
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id, created_at);,
+CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);,
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_id ON bookmarks(user_id, created_at);,
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_folder ON bookmarks(user_id, folder);,
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_user_post ON bookmarks(user_id, post_id) WHERE post_id IS NOT NULL;,
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_user_comment ON bookmarks(user_id, comment_id) WHERE comment_id IS NOT NULL;,
+CREATE INDEX IF NOT EXISTS idx_bookmarks_post_id ON bookmarks(post_id);,
+CREATE INDEX IF NOT EXISTS idx_bookmarks_comment_id ON bookmarks(comment_id);,
\ No newline at end of file
@@ -0,0 +1,12 @@
+package config
+
+const (
+	// Placeholder author that anonymised posts and comments are moved to
+	DELETED_USER_ID       = "00000000-0000-0000-0000-000000000000"
+	DELETED_USER_USERNAME = "deleted_user"
+	DELETED_USER_EMAIL    = "deleted_user@forum.invalid"
+
+	// Account deletion modes
+	DELETION_MODE_ERASE     = "erase"
+	DELETION_MODE_ANONYMISE = "anonymise"
+)
@@ -0,0 +1,7 @@
+package config
+
+const (
+	MAX_BOOKMARK_NOTE_LEN = 500
+
+	MAX_BOOKMARK_FOLDER_LEN = 50
+)
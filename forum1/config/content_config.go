@@ -0,0 +1,36 @@
+package config
+
+import "time"
+
+const (
+	MAX_POST_LEN    = 10000
+	MAX_COMMENT_LEN = 5000
+
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+
+	MAX_SEARCH_QUERY_LEN = 200
+
+	MAX_EDIT_REASON_LEN = 200
+
+	// Users mentioned beyond this many in one post or comment are neither
+	// linked nor notified
+	MAX_MENTIONS = 20
+
+	// How often scheduled drafts are checked for publication
+	SCHEDULED_PUBLISH_INTERVAL = 30 * time.Second
+
+	// Shown instead of the content of deleted posts and comments
+	DELETED_CONTENT_PLACEHOLDER = "[deleted]"
+
+	// Operations of a line-level diff between revisions
+	DIFF_EQUAL  = "equal"
+	DIFF_INSERT = "insert"
+	DIFF_DELETE = "delete"
+
+	// Diffs of longer texts fall back to replacing every changed line
+	MAX_DIFF_CELLS = 4_000_000
+
+	REACTION_LIKE    = 1
+	REACTION_DISLIKE = 2
+)
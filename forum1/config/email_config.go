@@ -0,0 +1,23 @@
+package config
+
+const (
+	EMAIL_DOMAIN_ALLOW = "allow"
+	EMAIL_DOMAIN_DENY  = "deny"
+)
+
+// EmailDomainConfig holds the static email domain rules from the environment.
+// Admins can add further rules at runtime; those are stored in the database.
+type EmailDomainConfig struct {
+	Allowlist       []string
+	Denylist        []string
+	BlockDisposable bool
+}
+
+// LoadEmailDomainConfig reads the email domain rules from the environment
+func LoadEmailDomainConfig() EmailDomainConfig {
+	return EmailDomainConfig{
+		Allowlist:       getEnvList("EMAIL_DOMAIN_ALLOWLIST"),
+		Denylist:        getEnvList("EMAIL_DOMAIN_DENYLIST"),
+		BlockDisposable: getEnvBool("BLOCK_DISPOSABLE_EMAILS", true),
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getEnvString returns the value of an environment variable or a default
+func getEnvString(key, def string) string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// getEnvInt returns an integer environment variable or a default
+func getEnvInt(key string, def int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// getEnvBool returns a boolean environment variable or a default
+func getEnvBool(key string, def bool) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// getEnvList returns a comma separated environment variable as a slice
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvDuration returns a duration environment variable (e.g. "72h") or a default
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return def
+	}
+	return value
+}
@@ -9,4 +9,28 @@ var (
 	ErrInvalidCredentials   = errors.New("invalid credentials")
 	ErrSessionNotFound      = errors.New("session not found")
 	ErrSessionExpired       = errors.New("session expired")
+	ErrInvalidDeletionMode  = errors.New("invalid deletion mode")
+	ErrAccountSuspended     = errors.New("account suspended")
+	ErrAccountBanned        = errors.New("account banned")
+	ErrSanctionNotFound     = errors.New("sanction not found")
+	ErrPostNotFound         = errors.New("post not found")
+	ErrCommentNotFound      = errors.New("comment not found")
+	ErrCategoryNotFound     = errors.New("category not found")
+	ErrBlocked              = errors.New("blocked by the author")
+	ErrInvalidRelation      = errors.New("cannot block or mute yourself")
+	ErrRegistrationClosed   = errors.New("registration is closed")
+	ErrInvalidInvite        = errors.New("invite code is invalid, expired or used up")
+	ErrInviteNotFound       = errors.New("invite not found")
+	ErrAccountPending       = errors.New("account awaiting approval")
+	ErrDomainRuleNotFound   = errors.New("email domain rule not found")
+	ErrSearchUnavailable    = errors.New("search index is not available")
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrInvalidAttachment    = errors.New("attachment is not one of your uploads")
+	ErrRevisionNotFound     = errors.New("revision not found")
+	ErrDraftNotFound        = errors.New("draft not found")
+	ErrReplyTargetNotFound  = errors.New("reply target not found")
+	ErrNotificationNotFound = errors.New("notification not found")
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrMessagingBlocked     = errors.New("cannot message a user who blocked you or whom you blocked")
+	ErrBookmarkNotFound     = errors.New("bookmark not found")
 )
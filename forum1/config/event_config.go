@@ -0,0 +1,73 @@
+package config
+
+import (
+	"net/url"
+	"time"
+)
+
+const (
+	EVENT_NOTIFICATION   = "notification"
+	EVENT_COMMENT        = "comment"
+	EVENT_REACTIONS      = "reactions"
+	EVENT_POST_EDITED    = "post_edited"
+	EVENT_COMMENT_EDITED = "comment_edited"
+	EVENT_RESYNC         = "resync" // events were missed and cannot be replayed
+	EVENT_PRESENCE       = "presence"
+	EVENT_TYPING         = "typing"
+	EVENT_MESSAGE        = "message"
+	EVENT_MESSAGES_READ  = "messages_read"
+)
+
+// MAX_STREAMED_THREADS is the number of threads one event stream can follow
+const MAX_STREAMED_THREADS = 20
+
+// EVENT_RETRY is how long clients wait before reconnecting a dropped stream
+const EVENT_RETRY = 3 * time.Second
+
+// MAX_LIVE_MESSAGE is the largest message, in bytes, a live thread client may send
+const MAX_LIVE_MESSAGE = 4096
+
+// TYPING_INTERVAL is how often a connection's typing indicator is passed on;
+// clients should show it for a little longer
+const TYPING_INTERVAL = 2 * time.Second
+
+// EventConfig controls the real-time event stream
+type EventConfig struct {
+	Heartbeat  time.Duration // idle streams get a comment line, and live threads a ping, this often
+	BufferSize int           // events queued per connection before it is dropped
+	ReplaySize int           // recent events kept for clients resuming with Last-Event-ID
+	Origin     string        // scheme and host of SERVER_URL, the only origin live threads accept
+}
+
+// LoadEventConfig reads the event stream settings from the environment.
+// Values that would break streams fall back to the defaults.
+func LoadEventConfig() EventConfig {
+	cfg := EventConfig{
+		Heartbeat:  getEnvDuration("SSE_HEARTBEAT", 15*time.Second),
+		BufferSize: getEnvInt("SSE_BUFFER_SIZE", 64),
+		ReplaySize: getEnvInt("SSE_REPLAY_SIZE", 1000),
+		Origin:     originOf(getEnvString("SERVER_URL", "")),
+	}
+
+	// Tickers panic on a non-positive interval
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.BufferSize < 0 {
+		cfg.BufferSize = 64
+	}
+	if cfg.ReplaySize < 0 {
+		cfg.ReplaySize = 1000
+	}
+
+	return cfg
+}
+
+// originOf returns the origin (scheme and host) of a URL, or "" if it has none
+func originOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
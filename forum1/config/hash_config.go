@@ -0,0 +1,73 @@
+package config
+
+const (
+	HASH_ALGORITHM_ARGON2ID = "argon2id"
+	HASH_ALGORITHM_BCRYPT   = "bcrypt"
+
+	// bcrypt only uses the first 72 bytes of a password
+	BCRYPT_MAX_PASSWORD_BYTES = 72
+
+	// Upper bounds for argon2id parameters, from the environment or a stored hash
+	MAX_ARGON2_MEMORY_KIB = 1024 * 1024
+	MAX_ARGON2_TIME       = 10
+)
+
+// HashConfig holds the parameters used when hashing new passwords
+type HashConfig struct {
+	Algorithm     string
+	Argon2Memory  uint32 // KiB
+	Argon2Time    uint32
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen uint32
+	BcryptCost    int
+	MaxConcurrent int // password hashes computed at once, each taking Argon2Memory
+}
+
+// DefaultHashConfig returns argon2id parameters suitable for small VMs
+func DefaultHashConfig() HashConfig {
+	return HashConfig{
+		Algorithm:     HASH_ALGORITHM_ARGON2ID,
+		Argon2Memory:  64 * 1024,
+		Argon2Time:    3,
+		Argon2Threads: 2,
+		Argon2KeyLen:  32,
+		Argon2SaltLen: 16,
+		BcryptCost:    12,
+		MaxConcurrent: 4,
+	}
+}
+
+// LoadHashConfig reads the password hashing parameters from the environment
+func LoadHashConfig() HashConfig {
+	cfg := DefaultHashConfig()
+
+	cfg.Algorithm = getEnvString("PASSWORD_HASH_ALGORITHM", cfg.Algorithm)
+	if cfg.Algorithm != HASH_ALGORITHM_BCRYPT {
+		cfg.Algorithm = HASH_ALGORITHM_ARGON2ID
+	}
+
+	if v := getEnvInt("ARGON2_MEMORY_KIB", 0); v > 0 && v <= MAX_ARGON2_MEMORY_KIB {
+		cfg.Argon2Memory = uint32(v)
+	}
+	if v := getEnvInt("ARGON2_TIME", 0); v > 0 && v <= MAX_ARGON2_TIME {
+		cfg.Argon2Time = uint32(v)
+	}
+	if v := getEnvInt("ARGON2_THREADS", 0); v > 0 && v <= 255 {
+		cfg.Argon2Threads = uint8(v)
+	}
+	if v := getEnvInt("ARGON2_KEY_LEN", 0); v >= 16 {
+		cfg.Argon2KeyLen = uint32(v)
+	}
+	if v := getEnvInt("ARGON2_SALT_LEN", 0); v >= 8 {
+		cfg.Argon2SaltLen = uint32(v)
+	}
+	if v := getEnvInt("BCRYPT_COST", 0); v >= 4 && v <= 31 {
+		cfg.BcryptCost = v
+	}
+	if v := getEnvInt("PASSWORD_HASH_CONCURRENCY", 0); v > 0 {
+		cfg.MaxConcurrent = v
+	}
+
+	return cfg
+}
@@ -0,0 +1,63 @@
+package config
+
+import "time"
+
+// IMPERSONATION_COOKIE holds the impersonation session, next to the admin's own session cookie
+const IMPERSONATION_COOKIE = "impersonation_id"
+
+// IMPERSONATION_ROUTES are the routes an admin can use while viewing the forum
+// as another user: the forum itself and the user's non-sensitive settings.
+// Everything else, such as the data export, security events, credentials,
+// drafts and private conversations, is refused. Write requests to them are
+// still subject to AllowWrites.
+var IMPERSONATION_ROUTES = []string{
+	"/{$}",
+	"/api/impersonation",
+	"/api/me",
+	"/api/me/profile",
+	"/api/me/blocks",
+	"/api/me/mutes",
+	"/api/me/bookmarks",
+	"/api/me/bookmarks/folders",
+	"/api/users",
+	"/api/users/{username}",
+	"/api/notifications",
+	"/api/notifications/unread-count",
+	"/api/notifications/read-all",
+	"/api/notifications/{id}/read",
+	"/api/events",
+	"/api/categories",
+	"/api/posts",
+	"/api/posts/{id}",
+	"/api/posts/{id}/comments",
+	"/api/posts/{id}/reactions",
+	"/api/posts/{id}/bookmark",
+	"/api/posts/{id}/revisions",
+	"/api/posts/{id}/live",
+	"/api/comments/{id}",
+	"/api/comments/{id}/reactions",
+	"/api/comments/{id}/bookmark",
+	"/api/comments/{id}/revisions",
+	"/api/preview",
+	"/api/search",
+	"/api/uploads",
+	"/uploads/{id}",
+	"/uploads/{id}/thumbnail",
+	"/blobs/{key...}",
+}
+
+// ImpersonationConfig controls the admin "view as user" mode
+type ImpersonationConfig struct {
+	TTL         time.Duration // impersonation sessions end on their own after this long
+	AllowWrites bool          // write requests are refused while impersonating unless set
+	MaxReason   int
+}
+
+// LoadImpersonationConfig reads the impersonation settings from the environment
+func LoadImpersonationConfig() ImpersonationConfig {
+	return ImpersonationConfig{
+		TTL:         getEnvDuration("IMPERSONATION_TTL", 30*time.Minute),
+		AllowWrites: getEnvBool("IMPERSONATION_ALLOW_WRITES", false),
+		MaxReason:   500,
+	}
+}
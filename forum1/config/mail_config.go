@@ -0,0 +1,24 @@
+package config
+
+// MailConfig holds the SMTP settings used for account notifications.
+// Without an SMTP host, emails are written to the log instead.
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	SiteURL  string // used in links inside emails
+}
+
+// LoadMailConfig reads the mail settings from the environment
+func LoadMailConfig() MailConfig {
+	return MailConfig{
+		Host:     getEnvString("SMTP_HOST", ""),
+		Port:     getEnvInt("SMTP_PORT", 587),
+		Username: getEnvString("SMTP_USERNAME", ""),
+		Password: getEnvString("SMTP_PASSWORD", ""),
+		From:     getEnvString("MAIL_FROM", "forum@localhost"),
+		SiteURL:  getEnvString("SERVER_URL", ""),
+	}
+}
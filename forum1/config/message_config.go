@@ -0,0 +1,10 @@
+package config
+
+const (
+	MAX_MESSAGE_LEN = 2000
+
+	// Members of a conversation, the user who started it included
+	MAX_CONVERSATION_MEMBERS = 10
+
+	MAX_CONVERSATION_TITLE_LEN = 100
+)
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+const (
+	SANCTION_WARNING    = "warning"
+	SANCTION_SUSPENSION = "suspension"
+	SANCTION_BAN        = "ban"
+)
+
+// TRASH_PURGE_INTERVAL is how often expired trash is hard deleted
+const TRASH_PURGE_INTERVAL = time.Hour
+
+// ModerationConfig controls automatic escalation of warnings
+type ModerationConfig struct {
+	WarningThreshold  int           // warnings within WarningWindow that trigger a suspension, 0 disables
+	WarningWindow     time.Duration // how far back warnings are counted
+	AutoSuspension    time.Duration // length of the automatic suspension
+	MaxSanctionReason int
+	TrashRetention    time.Duration // deleted posts and comments are hard deleted after this long
+}
+
+// LoadModerationConfig reads the moderation settings from the environment
+func LoadModerationConfig() ModerationConfig {
+	return ModerationConfig{
+		WarningThreshold:  getEnvInt("WARNINGS_BEFORE_SUSPENSION", 3),
+		WarningWindow:     getEnvDuration("WARNING_WINDOW", 30*24*time.Hour),
+		AutoSuspension:    getEnvDuration("AUTO_SUSPENSION_DURATION", 72*time.Hour),
+		MaxSanctionReason: 500,
+		TrashRetention:    getEnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+	}
+}
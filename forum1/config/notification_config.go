@@ -0,0 +1,18 @@
+package config
+
+const (
+	NOTIFICATION_REPLY_TO_POST    = "reply_to_post"
+	NOTIFICATION_REPLY_TO_COMMENT = "reply_to_comment"
+	NOTIFICATION_REACTION         = "reaction"
+	NOTIFICATION_MENTION          = "mention"
+	NOTIFICATION_MODERATION       = "moderation"
+)
+
+// NOTIFICATION_TYPES lists every notification type, in display order
+var NOTIFICATION_TYPES = []string{
+	NOTIFICATION_REPLY_TO_POST,
+	NOTIFICATION_REPLY_TO_COMMENT,
+	NOTIFICATION_REACTION,
+	NOTIFICATION_MENTION,
+	NOTIFICATION_MODERATION,
+}
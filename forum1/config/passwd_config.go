@@ -1,8 +1,78 @@
 package config
 
-import ()
+import "fmt"
 
 const (
-	MIN_PASSWORD_LEN = 1
-	MAX_PASSWORD_LEN = 19
-)
\ No newline at end of file
+	MIN_PASSWORD_LEN = 8
+	MAX_PASSWORD_LEN = 128
+)
+
+// PasswordPolicy describes the rules new passwords must satisfy
+type PasswordPolicy struct {
+	MinLength          int
+	MaxLength          int
+	MaxBytes           int // 0 for no limit; bcrypt ignores everything past BCRYPT_MAX_PASSWORD_BYTES
+	RequireUppercase   bool
+	RequireLowercase   bool
+	RequireNumber      bool
+	RequireSpecial     bool
+	MinEntropyBits     int      // 0 disables the entropy check
+	BannedSubstrings   []string // checked case-insensitively, in addition to username and email
+	BreachedListPath   string   // optional file of breached passwords or SHA-1 hashes, one per line
+	BanPersonalDetails bool     // reject passwords containing the username or email local part
+}
+
+// DefaultPasswordPolicy returns the policy used when nothing is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:          MIN_PASSWORD_LEN,
+		MaxLength:          MAX_PASSWORD_LEN,
+		RequireUppercase:   true,
+		RequireLowercase:   true,
+		RequireNumber:      true,
+		RequireSpecial:     true,
+		BanPersonalDetails: true,
+	}
+}
+
+// LoadPasswordPolicy reads the password policy from the environment. The
+// lengths must leave room for a password, also within what the hashing
+// algorithm can use.
+func LoadPasswordPolicy(hash HashConfig) (PasswordPolicy, error) {
+	policy := DefaultPasswordPolicy()
+
+	if v := getEnvInt("PASSWORD_MIN_LEN", 0); v > 0 {
+		policy.MinLength = v
+	}
+	if v := getEnvInt("PASSWORD_MAX_LEN", 0); v > 0 {
+		if v < policy.MinLength {
+			return policy, fmt.Errorf("PASSWORD_MAX_LEN (%d) must not be less than PASSWORD_MIN_LEN (%d)", v, policy.MinLength)
+		}
+		policy.MaxLength = v
+	} else if policy.MaxLength < policy.MinLength {
+		policy.MaxLength = policy.MinLength
+	}
+
+	// bcrypt silently ignores the rest of longer passwords
+	if hash.Algorithm == HASH_ALGORITHM_BCRYPT {
+		if policy.MinLength > BCRYPT_MAX_PASSWORD_BYTES {
+			return policy, fmt.Errorf("PASSWORD_MIN_LEN (%d) cannot exceed %d with bcrypt", policy.MinLength, BCRYPT_MAX_PASSWORD_BYTES)
+		}
+		policy.MaxLength = min(policy.MaxLength, BCRYPT_MAX_PASSWORD_BYTES)
+		policy.MaxBytes = BCRYPT_MAX_PASSWORD_BYTES
+	}
+
+	// A single switch keeps simple setups simple; entropy scoring can replace it
+	requireClasses := getEnvBool("PASSWORD_REQUIRE_CLASSES", true)
+	policy.RequireUppercase = requireClasses
+	policy.RequireLowercase = requireClasses
+	policy.RequireNumber = requireClasses
+	policy.RequireSpecial = requireClasses
+
+	policy.MinEntropyBits = getEnvInt("PASSWORD_MIN_ENTROPY_BITS", 0)
+	policy.BannedSubstrings = getEnvList("PASSWORD_BANNED_SUBSTRINGS")
+	policy.BreachedListPath = getEnvString("PASSWORD_BREACHED_LIST", "")
+	policy.BanPersonalDetails = getEnvBool("PASSWORD_BAN_PERSONAL_DETAILS", true)
+
+	return policy, nil
+}
@@ -0,0 +1,8 @@
+package config
+
+const (
+	MAX_DISPLAY_NAME_LEN = 50
+	MAX_BIO_LEN          = 500
+	MAX_LOCATION_LEN     = 100
+	MAX_PROFILE_URL_LEN  = 200
+)
@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+const (
+	REGISTRATION_OPEN     = "open"
+	REGISTRATION_CLOSED   = "closed"
+	REGISTRATION_INVITE   = "invite"
+	REGISTRATION_APPROVAL = "approval"
+
+	USER_STATUS_ACTIVE  = "active"
+	USER_STATUS_PENDING = "pending"
+)
+
+// RegistrationConfig controls who may create an account
+type RegistrationConfig struct {
+	Mode              string        // open, closed, invite or approval
+	TrustedAccountAge time.Duration // regular users older than this may create invites, 0 disables
+	TrustedMaxUses    int           // usage limit for invites created by non-staff users
+	DefaultInviteTTL  time.Duration
+	MaxInviteTTL      time.Duration
+	UniformResponses  bool // answer every valid registration the same way so emails cannot be enumerated
+}
+
+// LoadRegistrationConfig reads the registration settings from the environment
+func LoadRegistrationConfig() RegistrationConfig {
+	cfg := RegistrationConfig{
+		Mode:              getEnvString("REGISTRATION_MODE", REGISTRATION_OPEN),
+		TrustedAccountAge: getEnvDuration("INVITE_TRUSTED_ACCOUNT_AGE", 0),
+		TrustedMaxUses:    getEnvInt("INVITE_TRUSTED_MAX_USES", 5),
+		DefaultInviteTTL:  getEnvDuration("INVITE_DEFAULT_TTL", 7*24*time.Hour),
+		MaxInviteTTL:      getEnvDuration("INVITE_MAX_TTL", 90*24*time.Hour),
+		UniformResponses:  getEnvBool("REGISTRATION_UNIFORM_RESPONSES", false),
+	}
+
+	switch cfg.Mode {
+	case REGISTRATION_OPEN, REGISTRATION_CLOSED, REGISTRATION_INVITE, REGISTRATION_APPROVAL:
+	default:
+		cfg.Mode = REGISTRATION_OPEN
+	}
+
+	return cfg
+}
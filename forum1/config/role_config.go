@@ -0,0 +1,13 @@
+package config
+
+const (
+	ROLE_USER      = "user"
+	ROLE_MODERATOR = "moderator"
+	ROLE_ADMIN     = "admin"
+)
+
+// LoadAdminEmails returns the emails listed in ADMIN_EMAILS (comma separated),
+// whose accounts are promoted to admin on startup
+func LoadAdminEmails() []string {
+	return getEnvList("ADMIN_EMAILS")
+}
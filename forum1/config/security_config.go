@@ -0,0 +1,20 @@
+package config
+
+const (
+	SECURITY_EVENT_LOGIN            = "login"
+	SECURITY_EVENT_LOGIN_FAILED     = "login_failed"
+	SECURITY_EVENT_LOGOUT           = "logout"
+	SECURITY_EVENT_PASSWORD_CHANGED = "password_changed"
+	SECURITY_EVENT_EMAIL_CHANGED    = "email_changed"
+	SECURITY_EVENT_SESSIONS_REVOKED = "sessions_revoked"
+	SECURITY_EVENT_ROLE_CHANGED     = "role_changed"
+
+	SECURITY_EVENT_IMPERSONATION_STARTED = "impersonation_started"
+	SECURITY_EVENT_IMPERSONATION_ENDED   = "impersonation_ended"
+)
+
+// LoadNewDeviceAlerts reports whether users get an email when they log in
+// from a device that has not been seen on their account before
+func LoadNewDeviceAlerts() bool {
+	return getEnvBool("NEW_DEVICE_ALERTS", true)
+}
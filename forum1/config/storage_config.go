@@ -0,0 +1,46 @@
+package config
+
+import "time"
+
+// Storage backends for uploaded files
+const (
+	STORAGE_LOCAL = "local"
+	STORAGE_S3    = "s3"
+)
+
+// StorageConfig selects and configures where uploaded files are kept
+type StorageConfig struct {
+	Backend    string        // STORAGE_LOCAL or STORAGE_S3
+	URLTTL     time.Duration // download URLs stay valid for at least this long
+	LocalDir   string        // required by STORAGE_LOCAL; kept apart from the working directory
+	SigningKey string        // signs local download URLs; random per start when empty
+	S3         S3Config
+}
+
+// S3Config points at an S3-compatible bucket (AWS, MinIO, ...)
+type S3Config struct {
+	Endpoint        string // e.g. http://localhost:9000, default https://s3.<region>.amazonaws.com
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool // address the bucket as <endpoint>/<bucket>, needed by most stand-ins
+}
+
+// LoadStorageConfig reads the storage settings from the environment
+func LoadStorageConfig() StorageConfig {
+	return StorageConfig{
+		Backend:    getEnvString("STORAGE_BACKEND", STORAGE_LOCAL),
+		URLTTL:     getEnvDuration("STORAGE_URL_TTL", time.Hour),
+		LocalDir:   getEnvString("UPLOAD_DIR", ""),
+		SigningKey: getEnvString("STORAGE_SIGNING_KEY", ""),
+		S3: S3Config{
+			Endpoint:        getEnvString("S3_ENDPOINT", ""),
+			Region:          getEnvString("S3_REGION", "us-east-1"),
+			Bucket:          getEnvString("S3_BUCKET", ""),
+			AccessKeyID:     getEnvString("S3_ACCESS_KEY_ID", ""),
+			SecretAccessKey: getEnvString("S3_SECRET_ACCESS_KEY", ""),
+			PathStyle:       getEnvBool("S3_PATH_STYLE", false),
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import "fmt"
+
+// MAX_ATTACHMENTS is the number of images a post or comment can carry
+const MAX_ATTACHMENTS = 10
+
+// UploadConfig controls image uploads
+type UploadConfig struct {
+	MaxBytes      int64
+	MaxPixels     int // width * height, summed over the frames of animated GIFs
+	ThumbnailSize int // longest side of generated thumbnails
+}
+
+// LoadUploadConfig reads the upload settings from the environment. The
+// default pixel limit keeps a decoded image (4 bytes per pixel) and its
+// rotated copy within a small VM's memory.
+func LoadUploadConfig() (UploadConfig, error) {
+	cfg := UploadConfig{
+		MaxBytes:      int64(getEnvInt("UPLOAD_MAX_BYTES", 5<<20)),
+		MaxPixels:     getEnvInt("UPLOAD_MAX_PIXELS", 16_000_000),
+		ThumbnailSize: getEnvInt("THUMBNAIL_SIZE", 320),
+	}
+
+	if cfg.MaxBytes <= 0 {
+		return cfg, fmt.Errorf("UPLOAD_MAX_BYTES must be positive, got %d", cfg.MaxBytes)
+	}
+	if cfg.MaxPixels <= 0 {
+		return cfg, fmt.Errorf("UPLOAD_MAX_PIXELS must be positive, got %d", cfg.MaxPixels)
+	}
+	if cfg.ThumbnailSize <= 0 {
+		return cfg, fmt.Errorf("THUMBNAIL_SIZE must be positive, got %d", cfg.ThumbnailSize)
+	}
+
+	return cfg, nil
+}
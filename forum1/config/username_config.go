@@ -3,4 +3,23 @@ package config
 const (
 	MIN_USERNAME_LEN = 3
 	MAX_USERNAME_LEN = 15
+
+	// Usernames suggested by autocomplete
+	DEFAULT_USERNAME_SUGGESTIONS = 10
+	MAX_USERNAME_SUGGESTIONS     = 25
 )
+
+// defaultReservedUsernames cannot be registered by anyone
+var defaultReservedUsernames = []string{
+	"admin", "administrator", "root", "system", "sysadmin",
+	"moderator", "mod", "mods", "staff", "support", "help",
+	"security", "abuse", "postmaster", "webmaster", "hostmaster",
+	"api", "forum", "official", "anonymous", "null", "undefined",
+	"deleted", DELETED_USER_USERNAME,
+}
+
+// LoadReservedUsernames returns the built-in reserved usernames plus any
+// listed in RESERVED_USERNAMES (comma separated)
+func LoadReservedUsernames() []string {
+	return append(append([]string{}, defaultReservedUsernames...), getEnvList("RESERVED_USERNAMES")...)
+}
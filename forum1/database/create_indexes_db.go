@@ -23,8 +23,41 @@ func createIndexes(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_reactions_post_id ON reactions(post_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_reactions_comment_id ON reactions(comment_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_session_id ON sessions(session_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_sanctions_user_id ON user_sanctions(user_id, sanction_type);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks(blocked_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_invite_codes_created_by ON invite_codes(created_by);`,
+		`CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_impersonation_sessions_admin_id ON impersonation_sessions(admin_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_uploads_upload_id ON user_uploads(upload_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments(post_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_attachments_comment_id ON attachments(comment_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at) WHERE deleted_at IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments(deleted_at) WHERE deleted_at IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts(publish_at) WHERE draft = 1 AND publish_at IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_actor_id ON notifications(actor_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_post_id ON notifications(post_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_comment_id ON notifications(comment_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_reply_to ON comments(reply_to);`,
+		`CREATE INDEX IF NOT EXISTS idx_content_revisions_editor_id ON content_revisions(editor_id);`,
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_user_post ON reactions(user_id, post_id) WHERE post_id IS NOT NULL;`,
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_user_comment ON reactions(user_id, comment_id) WHERE comment_id IS NOT NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_content_revisions_post ON content_revisions(post_id, revision_number) WHERE post_id IS NOT NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_content_revisions_comment ON content_revisions(comment_id, revision_number) WHERE comment_id IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions(user_id);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_post ON mentions(post_id, user_id) WHERE post_id IS NOT NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_mentions_comment ON mentions(comment_id, user_id) WHERE comment_id IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_user_id ON bookmarks(user_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_user_folder ON bookmarks(user_id, folder);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_user_post ON bookmarks(user_id, post_id) WHERE post_id IS NOT NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_bookmarks_user_comment ON bookmarks(user_id, comment_id) WHERE comment_id IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_post_id ON bookmarks(post_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_comment_id ON bookmarks(comment_id);`,
 	}
 
 	// Execute each index creation statement
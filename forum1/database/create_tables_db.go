@@ -25,17 +25,35 @@ func createTables(db *sql.DB) error {
         		CHECK (username GLOB '[a-zA-Z0-9_]*')
         		CHECK (username NOT GLOB '*[^a-zA-Z0-9_]*'),
     		email TEXT NOT NULL UNIQUE,
-    		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    		username_canonical TEXT,
+    		email_canonical TEXT,
+    		role TEXT NOT NULL DEFAULT 'user'
+        		CHECK (role IN ('user', 'moderator', 'admin')),
+    		status TEXT NOT NULL DEFAULT 'active'
+        		CHECK (status IN ('active', 'pending'))
 		);`,
 
 		// User authentication table
 		`CREATE TABLE IF NOT EXISTS user_auth (
     		user_id TEXT PRIMARY KEY,
     		password_hash TEXT NOT NULL 
-        		CHECK (length(password_hash) = 60),
+        		CHECK (password_hash LIKE '$2_$%' OR password_hash LIKE '$argon2id$%'),
     		FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
 		);`,
 
+		// User profile table
+		`CREATE TABLE IF NOT EXISTS user_profile (
+			user_id TEXT PRIMARY KEY,
+			display_name TEXT NOT NULL DEFAULT '',
+			bio TEXT NOT NULL DEFAULT '',
+			location TEXT NOT NULL DEFAULT '',
+			website TEXT NOT NULL DEFAULT '',
+			avatar_url TEXT NOT NULL DEFAULT '',
+			updated_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+
 		// Sessions table
 		`CREATE TABLE IF NOT EXISTS sessions (
 			user_id TEXT PRIMARY KEY,
@@ -46,6 +64,22 @@ func createTables(db *sql.DB) error {
 			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
 		);`,
 
+		// User sanctions table (warnings, suspensions and bans)
+		`CREATE TABLE IF NOT EXISTS user_sanctions (
+			sanction_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			moderator_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000', -- placeholder user once the moderator is deleted
+			sanction_type TEXT NOT NULL
+				CHECK (sanction_type IN ('warning', 'suspension', 'ban')),
+			reason TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP,
+			revoked_at TIMESTAMP,
+			revoked_by TEXT,
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (moderator_id) REFERENCES user(user_id) ON DELETE SET DEFAULT
+		);`,
+
 		// Categories table
 		`CREATE TABLE IF NOT EXISTS categories (
     		category_id TEXT PRIMARY KEY,
@@ -61,6 +95,11 @@ func createTables(db *sql.DB) error {
 			content TEXT NOT NULL,
 			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			deleted_by TEXT REFERENCES user(user_id) ON DELETE SET NULL,
+			delete_reason TEXT NOT NULL DEFAULT '',
+			draft INTEGER NOT NULL DEFAULT 0 CHECK (draft IN (0, 1)),
+			publish_at TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
 			FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE CASCADE
 		);`,
@@ -73,6 +112,10 @@ func createTables(db *sql.DB) error {
 			content TEXT NOT NULL,
 			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			deleted_by TEXT REFERENCES user(user_id) ON DELETE SET NULL,
+			delete_reason TEXT NOT NULL DEFAULT '',
+			reply_to TEXT REFERENCES comments(comment_id) ON DELETE SET NULL,
 			FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
 			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
 		);`,
@@ -90,6 +133,232 @@ func createTables(db *sql.DB) error {
     		FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
     		CHECK ((post_id IS NULL AND comment_id IS NOT NULL) OR (post_id IS NOT NULL AND comment_id IS NULL))
 		);`,
+
+		// Invite codes table
+		`CREATE TABLE IF NOT EXISTS invite_codes (
+			code TEXT PRIMARY KEY,
+			created_by TEXT NOT NULL,
+			max_uses INTEGER NOT NULL CHECK (max_uses > 0),
+			uses INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP,
+			FOREIGN KEY (created_by) REFERENCES user(user_id) ON DELETE CASCADE,
+			CHECK (uses <= max_uses)
+		);`,
+
+		// Email domain rules table (runtime allow/deny lists managed by admins)
+		`CREATE TABLE IF NOT EXISTS email_domain_rules (
+			domain TEXT PRIMARY KEY,
+			rule_type TEXT NOT NULL CHECK (rule_type IN ('allow', 'deny')),
+			created_by TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (created_by) REFERENCES user(user_id) ON DELETE SET NULL
+		);`,
+
+		// User blocks table
+		`CREATE TABLE IF NOT EXISTS user_blocks (
+			blocker_id TEXT NOT NULL,
+			blocked_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (blocker_id, blocked_id),
+			FOREIGN KEY (blocker_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (blocked_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			CHECK (blocker_id != blocked_id)
+		);`,
+
+		// User mutes table
+		`CREATE TABLE IF NOT EXISTS user_mutes (
+			muter_id TEXT NOT NULL,
+			muted_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (muter_id, muted_id),
+			FOREIGN KEY (muter_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (muted_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			CHECK (muter_id != muted_id)
+		);`,
+
+		// Security events table
+		`CREATE TABLE IF NOT EXISTS security_events (
+			event_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			ip_address TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			details TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+
+		// Devices each user has logged in from
+		`CREATE TABLE IF NOT EXISTS user_devices (
+			user_id TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			user_agent TEXT NOT NULL DEFAULT '',
+			first_seen_at TIMESTAMP NOT NULL,
+			last_seen_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, fingerprint),
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+
+		// Impersonation sessions table (audit log of admins viewing as a user)
+		`CREATE TABLE IF NOT EXISTS impersonation_sessions (
+			impersonation_id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL UNIQUE,
+			admin_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000', -- placeholder user once the admin is deleted
+			target_user_id TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			ip_address TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP,
+			FOREIGN KEY (admin_id) REFERENCES user(user_id) ON DELETE SET DEFAULT,
+			FOREIGN KEY (target_user_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+
+		// Uploaded images, stored once per distinct file
+		`CREATE TABLE IF NOT EXISTS uploads (
+			upload_id TEXT PRIMARY KEY,
+			sha256 TEXT NOT NULL UNIQUE,
+			content_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			width INTEGER NOT NULL,
+			height INTEGER NOT NULL,
+			path TEXT NOT NULL,
+			thumbnail_path TEXT NOT NULL,
+			thumbnail_type TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);`,
+
+		// Users who uploaded each image (only they may attach it)
+		`CREATE TABLE IF NOT EXISTS user_uploads (
+			user_id TEXT NOT NULL,
+			upload_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, upload_id),
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (upload_id) REFERENCES uploads(upload_id) ON DELETE CASCADE
+		);`,
+
+		// Images attached to posts and comments
+		`CREATE TABLE IF NOT EXISTS attachments (
+			attachment_id TEXT PRIMARY KEY,
+			upload_id TEXT NOT NULL,
+			post_id TEXT,
+			comment_id TEXT,
+			position INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (upload_id) REFERENCES uploads(upload_id) ON DELETE CASCADE,
+			FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+			FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+			CHECK ((post_id IS NULL) != (comment_id IS NULL))
+		);`,
+
+		// Earlier versions of edited posts and comments
+		`CREATE TABLE IF NOT EXISTS content_revisions (
+			revision_id TEXT PRIMARY KEY,
+			post_id TEXT,
+			comment_id TEXT,
+			revision_number INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			editor_id TEXT NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000', -- placeholder user once the editor is deleted
+			reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+			FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+			FOREIGN KEY (editor_id) REFERENCES user(user_id) ON DELETE SET DEFAULT,
+			CHECK ((post_id IS NULL) != (comment_id IS NULL))
+		);`,
+
+		// Notifications about activity on a user's content and moderator actions
+		`CREATE TABLE IF NOT EXISTS notifications (
+			notification_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			actor_id TEXT,
+			notification_type TEXT NOT NULL
+				CHECK (notification_type IN ('reply_to_post', 'reply_to_comment', 'reaction', 'mention', 'moderation')),
+			post_id TEXT,
+			comment_id TEXT,
+			reaction_type INTEGER,
+			message TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			read_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (actor_id) REFERENCES user(user_id) ON DELETE SET NULL,
+			FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+			FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE
+		);`,
+
+		// Notification types a user turned on or off (missing rows are on)
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			user_id TEXT NOT NULL,
+			notification_type TEXT NOT NULL,
+			enabled INTEGER NOT NULL CHECK (enabled IN (0, 1)),
+			PRIMARY KEY (user_id, notification_type),
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+
+		// Mentions table (users @mentioned in a post or comment)
+		`CREATE TABLE IF NOT EXISTS mentions (
+			user_id TEXT NOT NULL,
+			post_id TEXT,
+			comment_id TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+			FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+			CHECK ((post_id IS NULL AND comment_id IS NOT NULL) OR (post_id IS NOT NULL AND comment_id IS NULL))
+		);`,
+
+		// Conversations table (private one-to-one and group messaging)
+		`CREATE TABLE IF NOT EXISTS conversations (
+			conversation_id TEXT PRIMARY KEY,
+			title TEXT NOT NULL DEFAULT '', -- group conversations only
+			direct_key TEXT UNIQUE, -- both user IDs, sorted, for one-to-one conversations
+			created_by TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_message_at TIMESTAMP,
+			FOREIGN KEY (created_by) REFERENCES user(user_id) ON DELETE SET NULL
+		);`,
+
+		// Conversation members table
+		`CREATE TABLE IF NOT EXISTS conversation_members (
+			conversation_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_read_at TIMESTAMP, -- everything sent up to then has been read
+			muted INTEGER NOT NULL DEFAULT 0 CHECK (muted IN (0, 1)),
+			PRIMARY KEY (conversation_id, user_id),
+			FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+
+		// Messages table
+		`CREATE TABLE IF NOT EXISTS messages (
+			message_id TEXT PRIMARY KEY,
+			conversation_id TEXT NOT NULL,
+			sender_id TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+			FOREIGN KEY (sender_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+
+		// Bookmarks table (posts and comments saved by a user)
+		`CREATE TABLE IF NOT EXISTS bookmarks (
+			bookmark_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			post_id TEXT,
+			comment_id TEXT,
+			folder TEXT NOT NULL DEFAULT '', -- '' when not filed in a folder
+			note TEXT NOT NULL DEFAULT '', -- private to the user
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE,
+			FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+			FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+			CHECK ((post_id IS NULL AND comment_id IS NOT NULL) OR (post_id IS NOT NULL AND comment_id IS NULL))
+		);`,
 	}
 
 	// Execute each table creation statement
@@ -38,15 +38,30 @@ func InitDB() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to create tables: %v", err)
 	}
 
+	if err := runMigrations(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+
 	if err := createIndexes(db); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to create indexes: %v", err)
 	}
 
+	if err := createSearchIndex(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create search index: %v", err)
+	}
+
 	if err := populateCategories(db); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to populate categories: %v", err)
 	}
 
+	if err := populateDeletedUser(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to populate placeholder user: %v", err)
+	}
+
 	return db, nil
 }
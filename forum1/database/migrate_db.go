@@ -0,0 +1,256 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"forum/utils"
+)
+
+// runMigrations upgrades databases created by older versions of the schema
+func runMigrations(db *sql.DB) error {
+	migrations := []struct {
+		name string
+		run  func(*sql.DB) error
+	}{
+		{"relax user_auth password_hash check", migrateUserAuthHashCheck},
+		{"canonical username and email columns", migrateCanonicalIdentity},
+		{"user role column", migrateUserRole},
+		{"user status column", migrateUserStatus},
+		{"soft delete columns", migrateSoftDelete},
+		{"post draft columns", migratePostDrafts},
+		{"comment reply column", migrateCommentReplies},
+	}
+
+	for _, migration := range migrations {
+		if err := migration.run(db); err != nil {
+			return fmt.Errorf("migration %q failed: %v", migration.name, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateUserAuthHashCheck rebuilds user_auth when it still enforces the
+// bcrypt-only length(password_hash) = 60 constraint
+func migrateUserAuthHashCheck(db *sql.DB) error {
+	var tableSQL string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'user_auth'").Scan(&tableSQL)
+	if err != nil {
+		return err
+	}
+
+	if !strings.Contains(tableSQL, "length(password_hash) = 60") {
+		return nil
+	}
+
+	// Start a transaction for atomicity
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`CREATE TABLE user_auth_new (
+    		user_id TEXT PRIMARY KEY,
+    		password_hash TEXT NOT NULL
+        		CHECK (password_hash LIKE '$2_$%' OR password_hash LIKE '$argon2id$%'),
+    		FOREIGN KEY (user_id) REFERENCES user(user_id) ON DELETE CASCADE
+		);`,
+		`INSERT INTO user_auth_new (user_id, password_hash) SELECT user_id, password_hash FROM user_auth;`,
+		`DROP TABLE user_auth;`,
+		`ALTER TABLE user_auth_new RENAME TO user_auth;`,
+	}
+
+	for _, stmt := range statements {
+		if _, err = tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to execute statement: %s: %v", stmt, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// migrateCanonicalIdentity backfills the canonical username and email columns
+// and adds unique indexes on them. Existing case-insensitive collisions are
+// reported and the affected index is skipped until they are resolved.
+func migrateCanonicalIdentity(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "user", "username_canonical", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "user", "email_canonical", "TEXT"); err != nil {
+		return err
+	}
+
+	// Canonical forms are computed in Go since SQLite's LOWER() only folds ASCII
+	rows, err := db.Query("SELECT user_id, username, email FROM user WHERE username_canonical IS NULL OR email_canonical IS NULL")
+	if err != nil {
+		return err
+	}
+
+	type identity struct{ userID, username, email string }
+	var pending []identity
+	for rows.Next() {
+		var id identity
+		if err := rows.Scan(&id.userID, &id.username, &id.email); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback()
+
+		for _, id := range pending {
+			_, err = tx.Exec(
+				"UPDATE user SET username_canonical = ?, email_canonical = ? WHERE user_id = ?",
+				utils.CanonicalUsername(id.username), utils.CanonicalEmail(id.email), id.userID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	columns := []struct{ column, display, index string }{
+		{"username_canonical", "username", "idx_user_username_canonical"},
+		{"email_canonical", "email", "idx_user_email_canonical"},
+	}
+
+	for _, c := range columns {
+		collisions, err := findCollisions(db, c.column, c.display)
+		if err != nil {
+			return err
+		}
+
+		if len(collisions) > 0 {
+			for _, collision := range collisions {
+				log.Printf("WARNING: %s collision, accounts must be renamed before %s can be enforced: %s", c.display, c.index, collision)
+			}
+			continue
+		}
+
+		stmt := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON user(%s);", c.index, c.column)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to execute statement: %s: %v", stmt, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateUserRole adds the role column to user tables created before roles existed
+func migrateUserRole(db *sql.DB) error {
+	return addColumnIfMissing(db, "user", "role", "TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin'))")
+}
+
+// migrateUserStatus adds the status column used by the approval queue
+func migrateUserStatus(db *sql.DB) error {
+	return addColumnIfMissing(db, "user", "status", "TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'pending'))")
+}
+
+// migrateSoftDelete adds the columns marking posts and comments as deleted
+func migrateSoftDelete(db *sql.DB) error {
+	for _, table := range []string{"posts", "comments"} {
+		columns := []struct{ name, definition string }{
+			{"deleted_at", "TIMESTAMP"},
+			{"deleted_by", "TEXT REFERENCES user(user_id) ON DELETE SET NULL"},
+			{"delete_reason", "TEXT NOT NULL DEFAULT ''"},
+		}
+		for _, column := range columns {
+			if err := addColumnIfMissing(db, table, column.name, column.definition); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migratePostDrafts adds the columns of draft and scheduled posts. Existing
+// posts default to published.
+func migratePostDrafts(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "posts", "draft", "INTEGER NOT NULL DEFAULT 0 CHECK (draft IN (0, 1))"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "posts", "publish_at", "TIMESTAMP")
+}
+
+// migrateCommentReplies adds the column linking a comment to the one it answers
+func migrateCommentReplies(db *sql.DB) error {
+	return addColumnIfMissing(db, "comments", "reply_to", "TEXT REFERENCES comments(comment_id) ON DELETE SET NULL")
+}
+
+// findCollisions lists the values of a column shared by several users
+// once canonicalised, e.g. "Bob@x.com, bob@x.com"
+func findCollisions(db *sql.DB, canonicalColumn, displayColumn string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT GROUP_CONCAT(%s, ', ') FROM user GROUP BY %s HAVING COUNT(*) > 1",
+		displayColumn, canonicalColumn,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collisions []string
+	for rows.Next() {
+		var collision string
+		if err := rows.Scan(&collision); err != nil {
+			return nil, err
+		}
+		collisions = append(collisions, collision)
+	}
+
+	return collisions, rows.Err()
+}
+
+// addColumnIfMissing adds a column to a table created by an older schema
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name, ctype  string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition)
+	if _, err := db.Exec(stmt); err != nil {
+		return fmt.Errorf("failed to execute statement: %s: %v", stmt, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"forum/config"
+	"forum/utils"
+)
+
+// populateDeletedUser creates the placeholder author used for anonymised
+// content. It has no user_auth row, so nobody can log in as it.
+func populateDeletedUser(db *sql.DB) error {
+	_, err := db.Exec(
+		"INSERT OR IGNORE INTO user (user_id, username, email, username_canonical, email_canonical) VALUES (?, ?, ?, ?, ?)",
+		config.DELETED_USER_ID, config.DELETED_USER_USERNAME, config.DELETED_USER_EMAIL,
+		utils.CanonicalUsername(config.DELETED_USER_USERNAME), utils.CanonicalEmail(config.DELETED_USER_EMAIL),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert placeholder user: %v", err)
+	}
+
+	return nil
+}
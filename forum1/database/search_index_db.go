@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// searchIndexes maps each FTS5 table to the table whose content it indexes.
+// They are external content tables keyed on the source table's rowid, so the
+// text is not stored twice.
+var searchIndexes = []struct {
+	name   string
+	source string
+}{
+	{"posts_fts", "posts"},
+	{"comments_fts", "comments"},
+}
+
+// createSearchIndex creates the full-text search tables and the triggers
+// keeping them in sync. SQLite builds without FTS5 (the go-sqlite3
+// "sqlite_fts5" build tag) only log a warning and leave search disabled.
+func createSearchIndex(db *sql.DB) error {
+	for _, index := range searchIndexes {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", index.name).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec(fmt.Sprintf(
+			`CREATE VIRTUAL TABLE IF NOT EXISTS %[1]s USING fts5(content, content='%[2]s', content_rowid='rowid', tokenize='unicode61 remove_diacritics 2');`,
+			index.name, index.source,
+		))
+		if err != nil {
+			if strings.Contains(err.Error(), "no such module: fts5") {
+				log.Printf("SQLite was built without FTS5, search is disabled (build with -tags sqlite_fts5)")
+				return nil
+			}
+			return err
+		}
+
+		triggers := []string{
+			`CREATE TRIGGER IF NOT EXISTS %[1]s_insert AFTER INSERT ON %[2]s BEGIN
+				INSERT INTO %[1]s (rowid, content) VALUES (new.rowid, new.content);
+			END;`,
+			`CREATE TRIGGER IF NOT EXISTS %[1]s_delete AFTER DELETE ON %[2]s BEGIN
+				INSERT INTO %[1]s (%[1]s, rowid, content) VALUES ('delete', old.rowid, old.content);
+			END;`,
+			`CREATE TRIGGER IF NOT EXISTS %[1]s_update AFTER UPDATE OF content ON %[2]s BEGIN
+				INSERT INTO %[1]s (%[1]s, rowid, content) VALUES ('delete', old.rowid, old.content);
+				INSERT INTO %[1]s (rowid, content) VALUES (new.rowid, new.content);
+			END;`,
+		}
+		for _, trigger := range triggers {
+			if _, err := db.Exec(fmt.Sprintf(trigger, index.name, index.source)); err != nil {
+				return fmt.Errorf("failed to create trigger for %s: %v", index.name, err)
+			}
+		}
+
+		// Index the rows that existed before the search table
+		if count == 0 {
+			if err := rebuildSearchTable(db, index.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RebuildSearchIndex rebuilds the full-text search tables from the posts and
+// comments tables. Run it after restoring data or running VACUUM, which can
+// renumber the rowids the index is keyed on.
+func RebuildSearchIndex(db *sql.DB) error {
+	for _, index := range searchIndexes {
+		if err := rebuildSearchTable(db, index.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSearchTable rebuilds one FTS5 table from its content table
+func rebuildSearchTable(db *sql.DB, name string) error {
+	_, err := db.Exec(fmt.Sprintf("INSERT INTO %[1]s (%[1]s) VALUES ('rebuild');", name))
+	if err != nil {
+		return fmt.Errorf("failed to rebuild %s: %v", name, err)
+	}
+	return nil
+}
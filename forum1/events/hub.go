@@ -0,0 +1,189 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event is a message published to the subscribers of a topic
+type Event struct {
+	ID       uint64 // 0 for events that are not kept for replay
+	Topic    string
+	Type     string
+	Data     []byte // JSON
+	AuthorID string // the user whose content the event carries, if any
+}
+
+// UserTopic is the topic of events meant for one user
+func UserTopic(userID string) string {
+	return "user:" + userID
+}
+
+// PostTopic is the topic of activity in a post's thread
+func PostTopic(postID string) string {
+	return "post:" + postID
+}
+
+// ThreadTopic is the topic of presence and typing in a post's live view.
+// Its events are broadcast, not kept for replay.
+func ThreadTopic(postID string) string {
+	return "thread:" + postID
+}
+
+// Hub fans events out to the subscribers in this process. It keeps the most
+// recent events so that clients reconnecting with the last ID they saw can
+// catch up on what they missed.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	evictedID   uint64  // newest event no longer in history
+	history     []Event // ring buffer of recent events
+	next        int     // position in a full history the next event goes to
+	subscribers map[*Subscription]struct{}
+	bufferSize  int
+}
+
+// Subscription receives the events of a set of topics. Events is closed when
+// the subscription is cancelled or falls too far behind.
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+	topics map[string]bool
+	hub    *Hub
+}
+
+// NewHub creates a hub queueing up to bufferSize events per subscriber and
+// remembering the last replaySize events
+func NewHub(bufferSize, replaySize int) *Hub {
+	bufferSize = max(bufferSize, 1)
+	replaySize = max(replaySize, 0)
+
+	// IDs start at the boot time, so IDs handed out before a restart are
+	// never taken for ones the new process has seen
+	bootID := uint64(time.Now().UnixMicro())
+
+	return &Hub{
+		lastID:      bootID,
+		evictedID:   bootID,
+		history:     make([]Event, 0, replaySize),
+		subscribers: map[*Subscription]struct{}{},
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish sends data, encoded as JSON, to the subscribers of topic. A
+// subscriber whose queue is full is dropped rather than holding up the
+// others; it can reconnect and resume from the history.
+func (h *Hub) Publish(topic, eventType string, data any, authorID string) {
+	h.send(topic, eventType, data, authorID, true)
+}
+
+// Broadcast sends data like Publish, but for short-lived state such as
+// typing indicators: the event gets no ID and is not kept for replay
+func (h *Hub) Broadcast(topic, eventType string, data any, authorID string) {
+	h.send(topic, eventType, data, authorID, false)
+}
+
+// send delivers an event to the subscribers of topic, recording it in the
+// history when it can be replayed
+func (h *Hub) send(topic, eventType string, data any, authorID string, replayable bool) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	event := Event{Topic: topic, Type: eventType, Data: payload, AuthorID: authorID}
+	if replayable {
+		h.lastID++
+		event.ID = h.lastID
+		h.remember(event)
+	}
+
+	for subscription := range h.subscribers {
+		if !subscription.topics[topic] {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			h.remove(subscription)
+		}
+	}
+}
+
+// Subscribe starts receiving the events of topics. With a lastEventID it
+// also returns the events of those topics published after it, and reports
+// whether the history still held all of them.
+func (h *Hub) Subscribe(topics []string, lastEventID uint64) (*Subscription, []Event, bool) {
+	events := make(chan Event, h.bufferSize)
+	subscription := &Subscription{
+		Events: events,
+		events: events,
+		topics: map[string]bool{},
+		hub:    h,
+	}
+	for _, topic := range topics {
+		subscription.topics[topic] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscribers[subscription] = struct{}{}
+	if lastEventID == 0 {
+		return subscription, nil, true
+	}
+
+	var missed []Event
+	for _, event := range h.ordered() {
+		if event.ID > lastEventID && subscription.topics[event.Topic] {
+			missed = append(missed, event)
+		}
+	}
+
+	complete := lastEventID >= h.evictedID && lastEventID <= h.lastID
+	return subscription, missed, complete
+}
+
+// Close cancels the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove unregisters a subscription and closes its channel. The caller holds the lock.
+func (h *Hub) remove(subscription *Subscription) {
+	if _, ok := h.subscribers[subscription]; ok {
+		delete(h.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+// remember adds an event to the history, evicting the oldest one when it is
+// full. The caller holds the lock.
+func (h *Hub) remember(event Event) {
+	switch {
+	case cap(h.history) == 0:
+		h.evictedID = event.ID
+	case len(h.history) < cap(h.history):
+		h.history = append(h.history, event)
+	default:
+		h.evictedID = h.history[h.next].ID
+		h.history[h.next] = event
+		h.next = (h.next + 1) % len(h.history)
+	}
+}
+
+// ordered returns the history oldest first. The caller holds the lock.
+func (h *Hub) ordered() []Event {
+	ordered := make([]Event, 0, len(h.history))
+	ordered = append(ordered, h.history[h.next:]...)
+	return append(ordered, h.history[:h.next]...)
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"sort"
+	"sync"
+
+	"forum/config"
+	"forum/models"
+)
+
+// Presence tracks who has each thread's live view open and broadcasts the
+// viewers whenever someone joins or leaves. A user counts once however many
+// connections they have open.
+type Presence struct {
+	mu      sync.Mutex
+	hub     *Hub
+	threads map[string]map[string]*presentViewer // post ID -> user ID
+}
+
+// presentViewer is a viewer and their number of open connections
+type presentViewer struct {
+	viewer      models.Viewer
+	connections int
+}
+
+// NewPresence creates a Presence broadcasting through hub
+func NewPresence(hub *Hub) *Presence {
+	return &Presence{
+		hub:     hub,
+		threads: map[string]map[string]*presentViewer{},
+	}
+}
+
+// Join records a new connection of viewer to a thread
+func (p *Presence) Join(postID string, viewer models.Viewer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	viewers := p.threads[postID]
+	if viewers == nil {
+		viewers = map[string]*presentViewer{}
+		p.threads[postID] = viewers
+	}
+	if present := viewers[viewer.UserID]; present != nil {
+		present.connections++
+	} else {
+		viewers[viewer.UserID] = &presentViewer{viewer: viewer, connections: 1}
+	}
+
+	// Broadcast even when the user was already present, so the new
+	// connection learns who else is there
+	p.broadcast(postID)
+}
+
+// Leave records that a connection of a user to a thread was closed
+func (p *Presence) Leave(postID, userID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	viewers := p.threads[postID]
+	present := viewers[userID]
+	if present == nil {
+		return
+	}
+
+	present.connections--
+	if present.connections > 0 {
+		return
+	}
+	delete(viewers, userID)
+	if len(viewers) == 0 {
+		delete(p.threads, postID)
+	}
+
+	p.broadcast(postID)
+}
+
+// broadcast sends the viewers of a thread, sorted by username. The caller
+// holds the lock, which keeps broadcasts in the order of the changes.
+func (p *Presence) broadcast(postID string) {
+	presence := models.ThreadPresence{Viewers: []models.Viewer{}}
+	for _, present := range p.threads[postID] {
+		presence.Viewers = append(presence.Viewers, present.viewer)
+	}
+	sort.Slice(presence.Viewers, func(i, j int) bool {
+		return presence.Viewers[i].Username < presence.Viewers[j].Username
+	})
+
+	p.hub.Broadcast(ThreadTopic(postID), config.EVENT_PRESENCE, presence, "")
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// AdminService handles administrator-only requests
+type AdminService struct {
+	UserRepo     *repository.UserRepository
+	DomainRepo   *repository.EmailDomainRepository
+	SecurityRepo *repository.SecurityEventRepository
+	EmailConfig  config.EmailDomainConfig
+}
+
+// NewAdminService creates a new AdminService
+func NewAdminService(userRepo *repository.UserRepository, domainRepo *repository.EmailDomainRepository, securityRepo *repository.SecurityEventRepository, emailConfig config.EmailDomainConfig) *AdminService {
+	return &AdminService{
+		UserRepo:     userRepo,
+		DomainRepo:   domainRepo,
+		SecurityRepo: securityRepo,
+		EmailConfig:  emailConfig,
+	}
+}
+
+// PendingRegistrations lists the accounts waiting for approval
+func PendingRegistrations(AdminService *AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		users, err := AdminService.UserRepo.ListPending()
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+	}
+}
+
+// ApproveRegistration activates a pending account
+func ApproveRegistration(AdminService *AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := AdminService.UserRepo.Approve(r.PathValue("id"))
+		if err != nil {
+			switch err {
+			case config.ErrUserNotFound:
+				http.Error(w, "Pending registration not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RejectRegistration deletes a pending account
+func RejectRegistration(AdminService *AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user, err := AdminService.UserRepo.GetByID(r.PathValue("id"))
+		if err != nil || user.Status != config.USER_STATUS_PENDING {
+			http.Error(w, "Pending registration not found", http.StatusNotFound)
+			return
+		}
+
+		err = AdminService.UserRepo.Delete(user.ID, config.DELETION_MODE_ERASE)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetUserRole grants or revokes the moderator role. Admins are managed
+// through ADMIN_EMAILS and cannot be changed here. The change is written to
+// the user's security events.
+func SetUserRole(AdminService *AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow PUT requests
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Parse request body
+		var change models.RoleChange
+		err := json.NewDecoder(r.Body).Decode(&change)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if change.Role != config.ROLE_USER && change.Role != config.ROLE_MODERATOR {
+			http.Error(w, "Role must be user or moderator", http.StatusBadRequest)
+			return
+		}
+
+		target, err := AdminService.UserRepo.GetByUsername(r.PathValue("username"))
+		if err == config.ErrUserNotFound || (err == nil && target.ID == config.DELETED_USER_ID) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if target.Role == config.ROLE_ADMIN {
+			http.Error(w, "Admins are managed through ADMIN_EMAILS", http.StatusConflict)
+			return
+		}
+
+		if target.Role != change.Role {
+			if err := AdminService.UserRepo.SetRole(target.ID, change.Role); err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			admin := middleware.GetCurrentUser(r)
+			details := fmt.Sprintf("Role changed from %s to %s by %s", target.Role, change.Role, admin.Username)
+			if err := AdminService.SecurityRepo.Record(target.ID, config.SECURITY_EVENT_ROLE_CHANGED, utils.ClientIP(r), r.UserAgent(), details); err != nil {
+				log.Printf("Failed to record role change of user %s: %v", target.ID, err)
+			}
+			target.Role = change.Role
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(target)
+	}
+}
+
+// EmailDomainRules handles GET (list) and POST (add or change) on the email domain rules
+func EmailDomainRules(AdminService *AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			rules, err := AdminService.DomainRepo.List()
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.EmailDomainRules{
+				Rules:           rules,
+				StaticAllowlist: AdminService.EmailConfig.Allowlist,
+				StaticDenylist:  AdminService.EmailConfig.Denylist,
+				BlockDisposable: AdminService.EmailConfig.BlockDisposable,
+			})
+
+		case http.MethodPost:
+			// Parse request body
+			var rule models.EmailDomainRule
+			err := json.NewDecoder(r.Body).Decode(&rule)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			rule.Domain = utils.NormalizeDomain(rule.Domain)
+			if rule.Domain == "" || !strings.Contains(rule.Domain, ".") {
+				http.Error(w, "Domain must be a valid domain such as example.com", http.StatusBadRequest)
+				return
+			}
+			if rule.RuleType != config.EMAIL_DOMAIN_ALLOW && rule.RuleType != config.EMAIL_DOMAIN_DENY {
+				http.Error(w, "Rule type must be allow or deny", http.StatusBadRequest)
+				return
+			}
+
+			saved, err := AdminService.DomainRepo.Upsert(rule.Domain, rule.RuleType, middleware.GetCurrentUser(r).ID)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(saved)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// DeleteEmailDomainRule removes an email domain rule
+func DeleteEmailDomainRule(AdminService *AdminService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow DELETE requests
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		err := AdminService.DomainRepo.Delete(utils.NormalizeDomain(r.PathValue("domain")))
+		if err != nil {
+			switch err {
+			case config.ErrDomainRuleNotFound:
+				http.Error(w, "Rule not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
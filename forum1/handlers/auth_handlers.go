@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"forum/config"
+	"forum/middleware"
 	"forum/models"
 	"forum/repository"
 	"forum/utils"
@@ -14,15 +18,27 @@ import (
 
 // AuthService handles authentication-related requests
 type AuthService struct {
-	UserRepo    *repository.UserRepository
-	SessionRepo *repository.SessionRepository
+	UserRepo     *repository.UserRepository
+	SessionRepo  *repository.SessionRepository
+	SanctionRepo *repository.SanctionRepository
+	InviteRepo   *repository.InviteRepository
+	DomainRepo   *repository.EmailDomainRepository
+	SecurityRepo *repository.SecurityEventRepository
+	Registration config.RegistrationConfig
+	DeviceAlerts bool // email users about logins from new devices
 }
 
 // AuthService creates a new AuthService
-func NewAuthService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository) *AuthService {
+func NewAuthService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, sanctionRepo *repository.SanctionRepository, inviteRepo *repository.InviteRepository, domainRepo *repository.EmailDomainRepository, securityRepo *repository.SecurityEventRepository, registration config.RegistrationConfig, deviceAlerts bool) *AuthService {
 	return &AuthService{
-		UserRepo:    userRepo,
-		SessionRepo: sessionRepo,
+		UserRepo:     userRepo,
+		SessionRepo:  sessionRepo,
+		SanctionRepo: sanctionRepo,
+		InviteRepo:   inviteRepo,
+		DomainRepo:   domainRepo,
+		SecurityRepo: securityRepo,
+		Registration: registration,
+		DeviceAlerts: deviceAlerts,
 	}
 }
 
@@ -43,6 +59,10 @@ func RegisterUser(AuthService *AuthService) http.HandlerFunc {
 			return
 		}
 
+		// Normalize username and email the same way login does
+		reg.Username = strings.TrimSpace(reg.Username)
+		reg.Email = utils.NormalizeEmail(reg.Email)
+
 		// validate username
 		err = utils.ValidateUsername(reg.Username)
 		if err != nil {
@@ -58,19 +78,78 @@ func RegisterUser(AuthService *AuthService) http.HandlerFunc {
 		}
 
 		// validate password
-		err = utils.ValidatePassword(reg.Password)
+		err = utils.ValidatePassword(reg.Password, reg.Username, reg.Email)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
+		// Check the email domain against the allow/deny lists
+		if !checkEmailDomain(w, AuthService.DomainRepo, reg.Email) {
+			return
+		}
+
+		mode := AuthService.Registration.Mode
+
+		// Closed forums accept no new accounts at all
+		if mode == config.REGISTRATION_CLOSED {
+			http.Error(w, "Registration is closed", http.StatusForbidden)
+			return
+		}
+
+		// With uniform responses the username is checked before anything
+		// touches the email, so a taken username cannot be paired with a
+		// target email to probe for accounts
+		uniform := AuthService.Registration.UniformResponses
+		if uniform {
+			_, err = AuthService.UserRepo.GetByUsername(reg.Username)
+			if err == nil {
+				http.Error(w, "Username is already taken", http.StatusConflict)
+				return
+			}
+			if err != config.ErrUserNotFound {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// Invite-only forums consume one use of a valid invite code
+		inviteMode := mode == config.REGISTRATION_INVITE
+		if inviteMode {
+			err = AuthService.InviteRepo.Redeem(reg.InviteCode)
+			if err != nil {
+				switch err {
+				case config.ErrInvalidInvite:
+					http.Error(w, "A valid invite code is required to register", http.StatusForbidden)
+				default:
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+		}
+
+		// Accounts wait for an admin in approval mode
+		status := config.USER_STATUS_ACTIVE
+		if mode == config.REGISTRATION_APPROVAL {
+			status = config.USER_STATUS_PENDING
+		}
+
 		// Create user
-		user, err := AuthService.UserRepo.Create(reg)
+		user, err := AuthService.UserRepo.Create(reg, status)
 		if err != nil {
-			switch err {
-			case config.ErrEmailTaken:
+			if inviteMode {
+				_ = AuthService.InviteRepo.Release(reg.InviteCode)
+			}
+			switch {
+			case err == config.ErrEmailTaken && uniform:
+				// Spend the hashing work a new account would have cost and
+				// tell the owner instead of the requester
+				_, _ = utils.HashPassword(reg.Password)
+				notifyExistingAccount(AuthService, reg.Email)
+				writeRegistrationAccepted(w, mode)
+			case err == config.ErrEmailTaken:
 				http.Error(w, "Email is already taken", http.StatusConflict)
-			case config.ErrUsernameTaken:
+			case err == config.ErrUsernameTaken:
 				http.Error(w, "Username is already taken", http.StatusConflict)
 			default:
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -78,9 +157,18 @@ func RegisterUser(AuthService *AuthService) http.HandlerFunc {
 			return
 		}
 
-		// Return response
+		if uniform {
+			writeRegistrationAccepted(w, mode)
+			return
+		}
+
+		// Return response (202 while the account awaits approval)
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		if user.Status == config.USER_STATUS_PENDING {
+			w.WriteHeader(http.StatusAccepted)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
 		json.NewEncoder(w).Encode(user)
 	}
 }
@@ -108,8 +196,8 @@ func LoginUser(AuthService *AuthService) http.HandlerFunc {
 			return
 		}
 
-		// Normalize email (convert to lowercase)
-		login.Email = strings.ToLower(login.Email)
+		// Normalize email (trim and convert to lowercase)
+		login.Email = utils.NormalizeEmail(login.Email)
 
 		// Basic email format validation
 		emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
@@ -123,6 +211,10 @@ func LoginUser(AuthService *AuthService) http.HandlerFunc {
 		if err != nil {
 			switch err {
 			case config.ErrInvalidCredentials, config.ErrUserNotFound:
+				// Logged against the account when the email exists
+				if err := AuthService.SecurityRepo.RecordByEmail(login.Email, config.SECURITY_EVENT_LOGIN_FAILED, utils.ClientIP(r), r.UserAgent(), "Wrong password"); err != nil {
+					log.Printf("Failed to record failed login: %v", err)
+				}
 				http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			default:
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -130,12 +222,40 @@ func LoginUser(AuthService *AuthService) http.HandlerFunc {
 			return
 		}
 
+		// Refuse login until the account has been approved
+		if user.Status == config.USER_STATUS_PENDING {
+			http.Error(w, "Your account is awaiting approval by an administrator", http.StatusForbidden)
+			return
+		}
+
+		// Refuse login while the account is suspended or banned
+		restriction, err := AuthService.SanctionRepo.GetActiveRestriction(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if restriction != nil {
+			recordSecurityEvent(AuthService.SecurityRepo, r, user.ID, config.SECURITY_EVENT_LOGIN_FAILED, "Account "+restriction.SanctionType)
+			http.Error(w, restrictionMessage(restriction), http.StatusForbidden)
+			return
+		}
+
 		// Create a new session
-		session, err := AuthService.SessionRepo.Create(user.ID, r.RemoteAddr)
+		session, replaced, err := AuthService.SessionRepo.Create(user.ID, r.RemoteAddr)
 		if err != nil {
 			http.Error(w, "Failed to create session", http.StatusInternalServerError)
 			return
 		}
+		if replaced > 0 {
+			recordSecurityEvent(AuthService.SecurityRepo, r, user.ID, config.SECURITY_EVENT_SESSIONS_REVOKED, fmt.Sprintf("Signed out of %d earlier session(s) by a new login", replaced))
+		}
+
+		// Log the login and warn the user about unfamiliar devices
+		details := ""
+		if alertNewDevice(AuthService.SecurityRepo, r, user, AuthService.DeviceAlerts) {
+			details = "New device"
+		}
+		recordSecurityEvent(AuthService.SecurityRepo, r, user.ID, config.SECURITY_EVENT_LOGIN, details)
 
 		// Set the session cookie
 		http.SetCookie(w, &http.Cookie{
@@ -158,6 +278,60 @@ func LoginUser(AuthService *AuthService) http.HandlerFunc {
 	}
 }
 
+// writeRegistrationAccepted sends the response used for every valid
+// registration when uniform responses are enabled
+func writeRegistrationAccepted(w http.ResponseWriter, mode string) {
+	message := "Registration received. If you cannot log in, check your email."
+	if mode == config.REGISTRATION_APPROVAL {
+		message = "Registration received. Your account can be used once an administrator approves it; if you already have an account, check your email."
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.RegistrationAccepted{Message: message})
+}
+
+// notifyExistingAccount tells the owner of an email that someone tried to
+// register with it
+func notifyExistingAccount(AuthService *AuthService, email string) {
+	user, err := AuthService.UserRepo.GetByEmail(email)
+	if err != nil {
+		log.Printf("Failed to look up existing account for registration notice: %v", err)
+		return
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Someone tried to create a new account at %s using this email address, which already belongs to your account.\n\n"+
+		"If this was you, log in with your existing account instead. If it was not, you can ignore this email; nothing has changed.\n",
+		user.Username, utils.SiteURL())
+	utils.SendMailAsync(user.Email, "Registration attempt with your email address", body)
+}
+
+// checkEmailDomain validates the domain of an email against the configured
+// and admin-managed rules, writing the error response when it is refused
+func checkEmailDomain(w http.ResponseWriter, domainRepo *repository.EmailDomainRepository, email string) bool {
+	allow, deny, err := domainRepo.Lists()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if err := utils.ValidateEmailDomain(email, allow, deny); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// restrictionMessage explains to a user why they cannot log in
+func restrictionMessage(sanction *models.Sanction) string {
+	if sanction.SanctionType == config.SANCTION_BAN {
+		return fmt.Sprintf("Your account has been banned: %s", sanction.Reason)
+	}
+	return fmt.Sprintf("Your account is suspended until %s: %s", sanction.ExpiresAt.UTC().Format(time.RFC1123), sanction.Reason)
+}
+
 // LogoutUser handles user logout
 func LogoutUser(AuthService *AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -183,6 +357,10 @@ func LogoutUser(AuthService *AuthService) http.HandlerFunc {
 			return
 		}
 
+		if user := middleware.GetCurrentUser(r); user != nil {
+			recordSecurityEvent(AuthService.SecurityRepo, r, user.ID, config.SECURITY_EVENT_LOGOUT, "")
+		}
+
 		// Clear the cookie
 		http.SetCookie(w, &http.Cookie{
 			Name:     "session_id",
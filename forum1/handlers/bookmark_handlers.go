@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// BookmarkPost handles PUT (save, or change folder and note) and DELETE on
+// /api/posts/{id}/bookmark
+func BookmarkPost(PostService *PostService) http.HandlerFunc {
+	return bookmarkHandler(PostService, "post_id")
+}
+
+// BookmarkComment handles PUT (save, or change folder and note) and DELETE on
+// /api/comments/{id}/bookmark
+func BookmarkComment(PostService *PostService) http.HandlerFunc {
+	return bookmarkHandler(PostService, "comment_id")
+}
+
+// bookmarkHandler builds a bookmark handler for the post or comment in targetColumn
+func bookmarkHandler(PostService *PostService, targetColumn string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+		targetID := r.PathValue("id")
+
+		switch r.Method {
+		case http.MethodPut:
+			// Parse request body; folder and note are optional
+			var creation models.BookmarkCreation
+			err := json.NewDecoder(r.Body).Decode(&creation)
+			if err != nil && err != io.EOF {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			creation.Folder = strings.TrimSpace(creation.Folder)
+			if utf8.RuneCountInString(creation.Folder) > config.MAX_BOOKMARK_FOLDER_LEN {
+				http.Error(w, fmt.Sprintf("Folder must be at most %d characters long", config.MAX_BOOKMARK_FOLDER_LEN), http.StatusBadRequest)
+				return
+			}
+			if utf8.RuneCountInString(creation.Note) > config.MAX_BOOKMARK_NOTE_LEN {
+				http.Error(w, fmt.Sprintf("Note must be at most %d characters long", config.MAX_BOOKMARK_NOTE_LEN), http.StatusBadRequest)
+				return
+			}
+
+			bookmark, created, err := PostService.BookmarkRepo.Save(user.ID, targetColumn, targetID, creation.Folder, creation.Note)
+			if err != nil {
+				writePostError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			if created {
+				w.WriteHeader(http.StatusCreated)
+			}
+			json.NewEncoder(w).Encode(bookmark)
+
+		case http.MethodDelete:
+			err := PostService.BookmarkRepo.Delete(user.ID, targetColumn, targetID)
+			if err != nil {
+				writePostError(w, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Bookmarks lists the current user's bookmarks, newest first, optionally
+// only those in one folder
+func Bookmarks(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		limit, offset := utils.ParsePagination(r)
+
+		var folder *string
+		if r.URL.Query().Has("folder") {
+			name := strings.TrimSpace(r.URL.Query().Get("folder"))
+			folder = &name
+		}
+
+		bookmarks, err := PostService.BookmarkRepo.List(user.ID, folder, limit, offset)
+		if err == nil {
+			err = loadBookmarkAttachments(PostService.UploadRepo, bookmarks)
+		}
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bookmarks)
+	}
+}
+
+// BookmarkFolders lists the folders the current user has filed bookmarks in
+func BookmarkFolders(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		folders, err := PostService.BookmarkRepo.ListFolders(middleware.GetCurrentUser(r).ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(folders)
+	}
+}
+
+// loadBookmarkAttachments fills in the attachments of the post or comment of each bookmark
+func loadBookmarkAttachments(uploadRepo *repository.UploadRepository, bookmarks []models.Bookmark) error {
+	for i := range bookmarks {
+		var err error
+		if post := bookmarks[i].Post; post != nil {
+			posts := []models.Post{*post}
+			err = loadPostAttachments(uploadRepo, posts)
+			bookmarks[i].Post = &posts[0]
+		} else if comment := bookmarks[i].Comment; comment != nil {
+			comments := []models.Comment{*comment}
+			err = loadCommentAttachments(uploadRepo, comments)
+			bookmarks[i].Comment = &comments[0]
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPostsBookmarked flags which posts the viewer has bookmarked. Posts
+// shown to anonymous visitors are left without the flag.
+func loadPostsBookmarked(bookmarkRepo *repository.BookmarkRepository, viewerID string, posts []models.Post) error {
+	if viewerID == "" {
+		return nil
+	}
+
+	postIDs := make([]string, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+
+	bookmarked, err := bookmarkRepo.ListBookmarked(viewerID, "post_id", postIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		flag := bookmarked[posts[i].ID]
+		posts[i].Bookmarked = &flag
+	}
+	return nil
+}
+
+// loadCommentsBookmarked flags which comments the viewer has bookmarked
+func loadCommentsBookmarked(bookmarkRepo *repository.BookmarkRepository, viewerID string, comments []models.Comment) error {
+	if viewerID == "" {
+		return nil
+	}
+
+	commentIDs := make([]string, len(comments))
+	for i := range comments {
+		commentIDs[i] = comments[i].ID
+	}
+
+	bookmarked, err := bookmarkRepo.ListBookmarked(viewerID, "comment_id", commentIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		flag := bookmarked[comments[i].ID]
+		comments[i].Bookmarked = &flag
+	}
+	return nil
+}
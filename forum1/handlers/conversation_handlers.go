@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// ConversationService handles private conversations between users
+type ConversationService struct {
+	ConversationRepo *repository.ConversationRepository
+	UserRepo         *repository.UserRepository
+	Events           *events.Hub
+}
+
+// NewConversationService creates a new ConversationService
+func NewConversationService(conversationRepo *repository.ConversationRepository, userRepo *repository.UserRepository, hub *events.Hub) *ConversationService {
+	return &ConversationService{
+		ConversationRepo: conversationRepo,
+		UserRepo:         userRepo,
+		Events:           hub,
+	}
+}
+
+// Conversations handles GET (list) and POST (start) on /api/conversations
+func Conversations(ConversationService *ConversationService) http.HandlerFunc {
+	return private(func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			limit, offset := utils.ParsePagination(r)
+			conversations, err := ConversationService.ConversationRepo.List(user.ID, limit, offset)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(conversations)
+
+		case http.MethodPost:
+			startConversation(ConversationService, w, r, user)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// startConversation starts a conversation with the users named in the
+// request, optionally sending a first message. A one-to-one conversation
+// that already exists is returned, with the message added to it.
+func startConversation(ConversationService *ConversationService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	// Parse request body
+	var creation models.ConversationCreation
+	err := json.NewDecoder(r.Body).Decode(&creation)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Look up the other members, each once
+	var memberIDs []string
+	for _, username := range creation.Usernames {
+		member, err := ConversationService.UserRepo.GetByUsername(username)
+		if err == config.ErrUserNotFound || (err == nil && (member.Status != config.USER_STATUS_ACTIVE || member.ID == config.DELETED_USER_ID)) {
+			http.Error(w, fmt.Sprintf("User %q not found", username), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if member.ID == user.ID {
+			http.Error(w, "You cannot start a conversation with yourself", http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(memberIDs, member.ID) {
+			memberIDs = append(memberIDs, member.ID)
+		}
+	}
+
+	if len(memberIDs) == 0 {
+		http.Error(w, "At least one username is required", http.StatusBadRequest)
+		return
+	}
+	if len(memberIDs)+1 > config.MAX_CONVERSATION_MEMBERS {
+		http.Error(w, fmt.Sprintf("Conversations can have at most %d members", config.MAX_CONVERSATION_MEMBERS), http.StatusBadRequest)
+		return
+	}
+
+	creation.Title = strings.TrimSpace(creation.Title)
+	if creation.Title != "" && len(memberIDs) == 1 {
+		http.Error(w, "Only group conversations can have a title", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(creation.Title) > config.MAX_CONVERSATION_TITLE_LEN {
+		http.Error(w, fmt.Sprintf("Title must be at most %d characters long", config.MAX_CONVERSATION_TITLE_LEN), http.StatusBadRequest)
+		return
+	}
+
+	// The first message is optional
+	if creation.Content != "" {
+		if err = utils.ValidateContent(creation.Content, config.MAX_MESSAGE_LEN); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	conversationID, created, err := ConversationService.ConversationRepo.Create(user.ID, memberIDs, creation.Title)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	if creation.Content != "" {
+		message, err := ConversationService.ConversationRepo.AddMessage(conversationID, user.ID, creation.Content)
+		if err != nil {
+			writeConversationError(w, err)
+			return
+		}
+		publishMessage(ConversationService, message)
+	}
+
+	conversation, err := ConversationService.ConversationRepo.Get(conversationID, user.ID)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// Conversation returns one of the current user's conversations with its
+// members and how far each has read
+func Conversation(ConversationService *ConversationService) http.HandlerFunc {
+	return private(func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		conversation, err := ConversationService.ConversationRepo.Get(r.PathValue("id"), user.ID)
+		if err != nil {
+			writeConversationError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conversation)
+	})
+}
+
+// ConversationMessages handles GET (list, newest first) and POST (send) on
+// /api/conversations/{id}/messages
+func ConversationMessages(ConversationService *ConversationService) http.HandlerFunc {
+	return private(func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+		conversationID := r.PathValue("id")
+
+		switch r.Method {
+		case http.MethodGet:
+			limit, offset := utils.ParsePagination(r)
+			messages, err := ConversationService.ConversationRepo.ListMessages(conversationID, user.ID, limit, offset)
+			if err != nil {
+				writeConversationError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(messages)
+
+		case http.MethodPost:
+			// Parse request body
+			var creation models.MessageCreation
+			err := json.NewDecoder(r.Body).Decode(&creation)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			// validate content
+			err = utils.ValidateContent(creation.Content, config.MAX_MESSAGE_LEN)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			message, err := ConversationService.ConversationRepo.AddMessage(conversationID, user.ID, creation.Content)
+			if err != nil {
+				writeConversationError(w, err)
+				return
+			}
+			publishMessage(ConversationService, message)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(message)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// MarkConversationRead records that the current user has read a
+// conversation up to now and tells its other members
+func MarkConversationRead(ConversationService *ConversationService) http.HandlerFunc {
+	return private(func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		conversationID := r.PathValue("id")
+
+		lastReadAt, err := ConversationService.ConversationRepo.MarkRead(conversationID, user.ID)
+		if err != nil {
+			writeConversationError(w, err)
+			return
+		}
+
+		read := models.MessagesRead{
+			ConversationID: conversationID,
+			UserID:         user.ID,
+			Username:       user.Username,
+			LastReadAt:     lastReadAt,
+		}
+		publishToMembers(ConversationService, conversationID, config.EVENT_MESSAGES_READ, read, "")
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// MuteConversation handles POST (mute) and DELETE (unmute) on
+// /api/conversations/{id}/mute. Muted conversations are left out of the
+// unread count.
+func MuteConversation(ConversationService *ConversationService) http.HandlerFunc {
+	return private(func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			err = ConversationService.ConversationRepo.SetMuted(r.PathValue("id"), user.ID, true)
+		case http.MethodDelete:
+			err = ConversationService.ConversationRepo.SetMuted(r.PathValue("id"), user.ID, false)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err != nil {
+			writeConversationError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// UnreadMessageCount returns how many messages the current user has not read
+// in the conversations they have not muted
+func UnreadMessageCount(ConversationService *ConversationService) http.HandlerFunc {
+	return private(func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		count, err := ConversationService.ConversationRepo.CountUnread(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.UnreadCount{Unread: count})
+	})
+}
+
+// private keeps conversations out of reach of admins viewing the forum as
+// another user
+func private(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if middleware.GetImpersonator(r) != nil {
+			http.Error(w, "Private conversations are not available while viewing as another user", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// publishMessage streams a new message to the members of its conversation.
+// Members who muted or blocked the sender do not get it.
+func publishMessage(ConversationService *ConversationService, message *models.Message) {
+	publishToMembers(ConversationService, message.ConversationID, config.EVENT_MESSAGE, message, message.SenderID)
+}
+
+// publishToMembers sends an event to every member of a conversation
+func publishToMembers(ConversationService *ConversationService, conversationID, eventType string, data any, authorID string) {
+	memberIDs, err := ConversationService.ConversationRepo.ListMemberIDs(conversationID)
+	if err != nil {
+		log.Printf("Failed to publish %s event of conversation %s: %v", eventType, conversationID, err)
+		return
+	}
+
+	for _, memberID := range memberIDs {
+		ConversationService.Events.Publish(events.UserTopic(memberID), eventType, data, authorID)
+	}
+}
+
+// writeConversationError maps conversation errors to HTTP responses
+func writeConversationError(w http.ResponseWriter, err error) {
+	switch err {
+	case config.ErrConversationNotFound:
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+	case config.ErrMessagingBlocked:
+		http.Error(w, "You cannot message a user who blocked you or whom you blocked", http.StatusForbidden)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// Drafts handles GET (list) and POST (create) on /api/drafts
+func Drafts(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			limit, offset := utils.ParsePagination(r)
+			drafts, err := PostService.DraftRepo.List(user.ID, limit, offset)
+			if err == nil {
+				err = loadDraftAttachments(PostService.UploadRepo, drafts)
+			}
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(drafts)
+
+		case http.MethodPost:
+			createDraft(PostService, w, r, user)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// createDraft starts a new draft. Only the category is required.
+func createDraft(PostService *PostService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	// Parse request body
+	var update models.DraftUpdate
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if update.CategoryID == nil {
+		http.Error(w, "Category is required", http.StatusBadRequest)
+		return
+	}
+
+	content := ""
+	if update.Content != nil {
+		content = *update.Content
+	}
+	err = utils.ValidateDraftContent(content, config.MAX_POST_LEN)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var attachments []string
+	if update.Attachments != nil {
+		var ok bool
+		attachments, ok = checkAttachments(w, PostService.UploadRepo, user.ID, *update.Attachments)
+		if !ok {
+			return
+		}
+	}
+
+	draft, err := PostService.DraftRepo.Create(user.ID, *update.CategoryID, content, attachments)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+
+	if len(attachments) > 0 {
+		drafts := []models.Draft{*draft}
+		err = loadDraftAttachments(PostService.UploadRepo, drafts)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		draft = &drafts[0]
+	}
+
+	// Mentions are recorded now but only notified once the draft is published
+	mentioned, _ := recordMentions(PostService, "post_id", draft.ID, user.ID, draft.Content)
+	draft.ContentHTML = utils.RenderMarkdown(draft.Content, mentioned)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// Draft handles GET, PATCH (autosave) and DELETE (discard) on /api/drafts/{id}
+func Draft(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			writeDraft(PostService, w, r.PathValue("id"), user.ID)
+
+		case http.MethodPatch:
+			saveDraft(PostService, w, r, user)
+
+		case http.MethodDelete:
+			if err := PostService.DraftRepo.Discard(r.PathValue("id"), user.ID); err != nil {
+				writeDraftError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// saveDraft applies the fields present in the request to a draft. A scheduled
+// draft must stay publishable, so its content cannot be emptied.
+func saveDraft(PostService *PostService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	draft, err := PostService.DraftRepo.Get(r.PathValue("id"), user.ID)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+
+	// Parse request body
+	var update models.DraftUpdate
+	err = json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if update.CategoryID != nil {
+		draft.CategoryID = *update.CategoryID
+	}
+	if update.Content != nil {
+		draft.Content = *update.Content
+	}
+
+	if draft.PublishAt != nil {
+		err = utils.ValidateContent(draft.Content, config.MAX_POST_LEN)
+	} else {
+		err = utils.ValidateDraftContent(draft.Content, config.MAX_POST_LEN)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var attachments []string
+	if update.Attachments != nil {
+		var ok bool
+		attachments, ok = checkAttachments(w, PostService.UploadRepo, user.ID, *update.Attachments)
+		if !ok {
+			return
+		}
+	}
+
+	err = PostService.DraftRepo.Update(draft.ID, user.ID, draft.CategoryID, draft.Content)
+	if err == nil && update.Attachments != nil {
+		err = PostService.UploadRepo.ReplacePostAttachments(draft.ID, attachments)
+	}
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+	recordMentions(PostService, "post_id", draft.ID, user.ID, draft.Content)
+
+	writeDraft(PostService, w, draft.ID, user.ID)
+}
+
+// PublishDraft handles POST (publish now or schedule) and DELETE (cancel the
+// schedule) on /api/drafts/{id}/publish
+func PublishDraft(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodPost:
+			publishDraft(PostService, w, r, user)
+
+		case http.MethodDelete:
+			if err := PostService.DraftRepo.Schedule(r.PathValue("id"), user.ID, nil); err != nil {
+				writeDraftError(w, err)
+				return
+			}
+			writeDraft(PostService, w, r.PathValue("id"), user.ID)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// publishDraft publishes a draft right away, or schedules it when a future
+// publish_at is given
+func publishDraft(PostService *PostService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	draft, err := PostService.DraftRepo.Get(r.PathValue("id"), user.ID)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+
+	// Parse request body; the schedule is optional
+	var schedule models.DraftSchedule
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Only publishable content can be published or scheduled
+	err = utils.ValidateContent(draft.Content, config.MAX_POST_LEN)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if schedule.PublishAt != nil {
+		if !schedule.PublishAt.After(time.Now()) {
+			http.Error(w, "Publish time must be in the future", http.StatusBadRequest)
+			return
+		}
+
+		if err = PostService.DraftRepo.Schedule(draft.ID, user.ID, schedule.PublishAt); err != nil {
+			writeDraftError(w, err)
+			return
+		}
+		writeDraft(PostService, w, draft.ID, user.ID)
+		return
+	}
+
+	if err = PostService.DraftRepo.Publish(draft.ID, user.ID); err != nil {
+		writeDraftError(w, err)
+		return
+	}
+
+	// Everyone the post mentions hears about it now
+	NotifyPublished(PostService.MentionRepo, PostService.NotificationRepo, PostService.Events, draft.ID)
+
+	writePost(PostService, w, draft.ID)
+}
+
+// writeDraft responds with one of the user's drafts and its attachments
+func writeDraft(PostService *PostService, w http.ResponseWriter, draftID, userID string) {
+	draft, err := PostService.DraftRepo.Get(draftID, userID)
+	if err != nil {
+		writeDraftError(w, err)
+		return
+	}
+
+	drafts := []models.Draft{*draft}
+	if err := loadDraftAttachments(PostService.UploadRepo, drafts); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(drafts[0])
+}
+
+// writeDraftError maps draft repository errors to HTTP responses
+func writeDraftError(w http.ResponseWriter, err error) {
+	switch err {
+	case config.ErrDraftNotFound:
+		http.Error(w, "Draft not found", http.StatusNotFound)
+	case config.ErrCategoryNotFound:
+		http.Error(w, "Category not found", http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
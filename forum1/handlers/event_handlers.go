@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
+	"forum/repository"
+)
+
+// EventService streams real-time updates to connected clients
+type EventService struct {
+	Hub              *events.Hub
+	Presence         *events.Presence
+	PostRepo         *repository.PostRepository
+	SessionRepo      *repository.SessionRepository
+	RelationshipRepo *repository.RelationshipRepository
+	Config           config.EventConfig
+}
+
+// NewEventService creates a new EventService
+func NewEventService(hub *events.Hub, presence *events.Presence, postRepo *repository.PostRepository, sessionRepo *repository.SessionRepository, relationshipRepo *repository.RelationshipRepository, cfg config.EventConfig) *EventService {
+	return &EventService{
+		Hub:              hub,
+		Presence:         presence,
+		PostRepo:         postRepo,
+		SessionRepo:      sessionRepo,
+		RelationshipRepo: relationshipRepo,
+		Config:           cfg,
+	}
+}
+
+// Events streams the current user's notifications, and new comments and
+// reaction totals of the threads listed in "posts", as Server-Sent Events.
+// A client reconnecting with Last-Event-ID gets the events it missed, or a
+// resync event when they are no longer available.
+func Events(EventService *EventService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		impersonated := middleware.GetImpersonator(r) != nil
+
+		topics := []string{events.UserTopic(user.ID)}
+		postIDs := strings.Split(r.URL.Query().Get("posts"), ",")
+		if len(postIDs) > config.MAX_STREAMED_THREADS {
+			http.Error(w, fmt.Sprintf("At most %d threads can be followed at once", config.MAX_STREAMED_THREADS), http.StatusBadRequest)
+			return
+		}
+		for _, postID := range postIDs {
+			postID = strings.TrimSpace(postID)
+			if postID == "" {
+				continue
+			}
+			if _, err := EventService.PostRepo.GetByID(postID); err != nil {
+				writePostError(w, err)
+				return
+			}
+			topics = append(topics, events.PostTopic(postID))
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Browsers send the header when reconnecting; the query parameter
+		// lets a client resume a stream it opened itself
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+		resumeFrom, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+		subscription, missed, complete := EventService.Hub.Subscribe(topics, resumeFrom)
+		defer subscription.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "retry: %d\n\n", config.EVENT_RETRY.Milliseconds())
+		if !complete {
+			fmt.Fprintf(w, "event: %s\ndata: {}\n\n", config.EVENT_RESYNC)
+		}
+		for _, event := range missed {
+			if !writeEvent(EventService, w, event, user.ID, impersonated) {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(EventService.Config.Heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case event, ok := <-subscription.Events:
+				if !ok {
+					log.Printf("Dropped the event stream of user %s, it fell behind", user.ID)
+					return
+				}
+				if !writeEvent(EventService, w, event, user.ID, impersonated) {
+					return
+				}
+				flusher.Flush()
+
+			case <-heartbeat.C:
+				// End the stream once the session is gone, e.g. after logging
+				// out or being suspended
+				if !sessionAlive(EventService, r) {
+					return
+				}
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// writeEvent writes an event in the Server-Sent Events format, leaving out
+// content by authors the viewer muted or blocked, and private conversations
+// when an admin is viewing the forum as the user. It returns false once the
+// client can no longer be written to.
+func writeEvent(EventService *EventService, w http.ResponseWriter, event events.Event, viewerID string, impersonated bool) bool {
+	if impersonated && (event.Type == config.EVENT_MESSAGE || event.Type == config.EVENT_MESSAGES_READ) {
+		return true
+	}
+	if hiddenFrom(EventService, event, viewerID) {
+		return true
+	}
+
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err == nil
+}
+
+// hiddenFrom reports whether an event carries content by an author the
+// viewer muted or blocked. Events are withheld when that cannot be checked.
+func hiddenFrom(EventService *EventService, event events.Event, viewerID string) bool {
+	if event.AuthorID == "" || event.AuthorID == viewerID {
+		return false
+	}
+
+	hidden, err := EventService.RelationshipRepo.Hides(viewerID, event.AuthorID)
+	if err != nil {
+		log.Printf("Failed to check relationships: %v", err)
+		return true
+	}
+	return hidden
+}
+
+// sessionAlive reports whether the session the stream was opened with is still valid
+func sessionAlive(EventService *EventService, r *http.Request) bool {
+	cookie, err := r.Cookie("session_id")
+	if err != nil {
+		return false
+	}
+	_, err = EventService.SessionRepo.GetBySessionID(cookie.Value)
+	return err == nil
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// ImpersonationService handles the admin "view as user" mode
+type ImpersonationService struct {
+	UserRepo     *repository.UserRepository
+	SessionRepo  *repository.SessionRepository
+	SecurityRepo *repository.SecurityEventRepository
+	Config       config.ImpersonationConfig
+}
+
+// NewImpersonationService creates a new ImpersonationService
+func NewImpersonationService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, securityRepo *repository.SecurityEventRepository, cfg config.ImpersonationConfig) *ImpersonationService {
+	return &ImpersonationService{
+		UserRepo:     userRepo,
+		SessionRepo:  sessionRepo,
+		SecurityRepo: securityRepo,
+		Config:       cfg,
+	}
+}
+
+// StartImpersonation lets an admin view the forum as another user
+func StartImpersonation(ImpersonationService *ImpersonationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		admin := middleware.GetCurrentUser(r)
+
+		// Parse request body
+		var req models.ImpersonationRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Every impersonation needs a reason for the audit log
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" || len(req.Reason) > ImpersonationService.Config.MaxReason {
+			http.Error(w, fmt.Sprintf("Reason is required and must be at most %d characters long", ImpersonationService.Config.MaxReason), http.StatusBadRequest)
+			return
+		}
+
+		target, err := ImpersonationService.UserRepo.GetByUsername(r.PathValue("username"))
+		if err != nil {
+			switch err {
+			case config.ErrUserNotFound:
+				http.Error(w, "User not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// Admins cannot take over other admins, themselves or the placeholder user
+		if target.ID == admin.ID || target.ID == config.DELETED_USER_ID || target.Role == config.ROLE_ADMIN {
+			http.Error(w, "You cannot view the forum as this user", http.StatusForbidden)
+			return
+		}
+
+		impersonation, err := ImpersonationService.SessionRepo.CreateImpersonation(admin.ID, target.ID, req.Reason, utils.ClientIP(r), ImpersonationService.Config.TTL)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Record the start in both users' security logs
+		recordSecurityEvent(ImpersonationService.SecurityRepo, r, admin.ID, config.SECURITY_EVENT_IMPERSONATION_STARTED, fmt.Sprintf("Viewing as %s: %s", target.Username, req.Reason))
+		recordSecurityEvent(ImpersonationService.SecurityRepo, r, target.ID, config.SECURITY_EVENT_IMPERSONATION_STARTED, fmt.Sprintf("Administrator %s is viewing the forum as you: %s", admin.Username, req.Reason))
+
+		// The impersonation cookie sits next to the admin's own session cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:     config.IMPERSONATION_COOKIE,
+			Value:    impersonation.SessionID,
+			Path:     "/",
+			Expires:  impersonation.ExpiresAt,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Impersonated-By", admin.Username)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.ImpersonationStatus{
+			Impersonation: *impersonation,
+			Admin:         *admin,
+			ViewingAs:     *target,
+			WritesAllowed: ImpersonationService.Config.AllowWrites,
+		})
+	}
+}
+
+// Impersonation handles GET (status) and DELETE (stop) on the running impersonation
+func Impersonation(ImpersonationService *ImpersonationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin := middleware.GetImpersonator(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			if admin == nil {
+				http.Error(w, "You are not viewing the forum as another user", http.StatusNotFound)
+				return
+			}
+
+			cookie, err := r.Cookie(config.IMPERSONATION_COOKIE)
+			if err != nil {
+				http.Error(w, "You are not viewing the forum as another user", http.StatusNotFound)
+				return
+			}
+			impersonation, err := ImpersonationService.SessionRepo.GetImpersonation(cookie.Value)
+			if err != nil {
+				http.Error(w, "You are not viewing the forum as another user", http.StatusNotFound)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.ImpersonationStatus{
+				Impersonation: *impersonation,
+				Admin:         *admin,
+				ViewingAs:     *middleware.GetCurrentUser(r),
+				WritesAllowed: ImpersonationService.Config.AllowWrites,
+			})
+
+		case http.MethodDelete:
+			stopImpersonation(ImpersonationService, w, r, admin)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// stopImpersonation ends the running impersonation and clears its cookie
+func stopImpersonation(ImpersonationService *ImpersonationService, w http.ResponseWriter, r *http.Request, admin *models.User) {
+	// Clear the cookie even if the impersonation already expired
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.IMPERSONATION_COOKIE,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	cookie, err := r.Cookie(config.IMPERSONATION_COOKIE)
+	if admin == nil || err != nil {
+		http.Error(w, "You are not viewing the forum as another user", http.StatusNotFound)
+		return
+	}
+
+	err = ImpersonationService.SessionRepo.EndImpersonation(cookie.Value)
+	if err != nil {
+		switch err {
+		case config.ErrSessionNotFound:
+			http.Error(w, "You are not viewing the forum as another user", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Record the end in both users' security logs
+	target := middleware.GetCurrentUser(r)
+	recordSecurityEvent(ImpersonationService.SecurityRepo, r, admin.ID, config.SECURITY_EVENT_IMPERSONATION_ENDED, "Stopped viewing as "+target.Username)
+	recordSecurityEvent(ImpersonationService.SecurityRepo, r, target.ID, config.SECURITY_EVENT_IMPERSONATION_ENDED, fmt.Sprintf("Administrator %s stopped viewing the forum as you", admin.Username))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListImpersonations returns a page of the impersonation audit log
+func ListImpersonations(ImpersonationService *ImpersonationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		limit, offset := utils.ParsePagination(r)
+		sessions, err := ImpersonationService.SessionRepo.ListImpersonations(limit, offset)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sessions)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+)
+
+// InviteService handles invite codes for invite-only registration
+type InviteService struct {
+	InviteRepo   *repository.InviteRepository
+	Registration config.RegistrationConfig
+}
+
+// NewInviteService creates a new InviteService
+func NewInviteService(inviteRepo *repository.InviteRepository, registration config.RegistrationConfig) *InviteService {
+	return &InviteService{
+		InviteRepo:   inviteRepo,
+		Registration: registration,
+	}
+}
+
+// Invites handles GET (list my invites) and POST (create an invite) on /api/invites
+func Invites(InviteService *InviteService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			invites, err := InviteService.InviteRepo.ListByCreator(user.ID)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(invites)
+
+		case http.MethodPost:
+			createInvite(InviteService, w, r, user)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// createInvite creates an invite code for an admin or trusted user
+func createInvite(InviteService *InviteService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	cfg := InviteService.Registration
+
+	if !canInvite(cfg, user) {
+		http.Error(w, "You are not allowed to create invites", http.StatusForbidden)
+		return
+	}
+
+	// Parse request body
+	var creation models.InviteCreation
+	err := json.NewDecoder(r.Body).Decode(&creation)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if creation.MaxUses == 0 {
+		creation.MaxUses = 1
+	}
+	if creation.MaxUses < 0 {
+		http.Error(w, "Max uses must be positive", http.StatusBadRequest)
+		return
+	}
+	if user.Role != config.ROLE_ADMIN && creation.MaxUses > cfg.TrustedMaxUses {
+		http.Error(w, fmt.Sprintf("Invites can be used at most %d times", cfg.TrustedMaxUses), http.StatusBadRequest)
+		return
+	}
+
+	ttl := cfg.DefaultInviteTTL
+	if creation.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(creation.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			http.Error(w, "Expires in must be a positive duration such as \"168h\"", http.StatusBadRequest)
+			return
+		}
+	}
+	if ttl > cfg.MaxInviteTTL {
+		http.Error(w, fmt.Sprintf("Invites can last at most %s", cfg.MaxInviteTTL), http.StatusBadRequest)
+		return
+	}
+
+	invite, err := InviteService.InviteRepo.Create(user.ID, creation.MaxUses, time.Now().Add(ttl))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+// RevokeInvite disables an invite code before it expires
+func RevokeInvite(InviteService *InviteService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow DELETE requests
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		err := InviteService.InviteRepo.Revoke(r.PathValue("code"), user.ID, user.Role == config.ROLE_ADMIN)
+		if err != nil {
+			switch err {
+			case config.ErrInviteNotFound:
+				http.Error(w, "Invite not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// canInvite reports whether a user may create invites: staff always, regular
+// users once their account is older than the configured trust threshold
+func canInvite(cfg config.RegistrationConfig, user *models.User) bool {
+	if user.Role == config.ROLE_ADMIN || user.Role == config.ROLE_MODERATOR {
+		return true
+	}
+	return cfg.TrustedAccountAge > 0 && time.Since(user.CreatedAt) >= cfg.TrustedAccountAge
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
+	"forum/models"
+	"forum/websocket"
+)
+
+// LiveThread upgrades to a WebSocket carrying a thread's new comments,
+// edits and reaction totals, who else is viewing it and who is typing.
+// Clients send {"type": "typing"} while the user writes a comment.
+func LiveThread(EventService *EventService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Browsers send cookies with WebSocket requests from any site, so
+		// only pages of the forum itself may open one
+		if origin := r.Header.Get("Origin"); origin != "" && !strings.EqualFold(origin, EventService.Config.Origin) {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		post, err := EventService.PostRepo.GetByID(r.PathValue("id"))
+		if err != nil {
+			writePostError(w, err)
+			return
+		}
+
+		conn, err := websocket.Upgrade(w, r, config.MAX_LIVE_MESSAGE, 2*EventService.Config.Heartbeat)
+		if err != nil {
+			return
+		}
+
+		subscription, _, _ := EventService.Hub.Subscribe([]string{events.PostTopic(post.ID), events.ThreadTopic(post.ID)}, 0)
+		defer subscription.Close()
+
+		// Admins viewing the forum as someone else neither show up nor type
+		// in their name
+		viewer := models.Viewer{UserID: user.ID, Username: user.Username}
+		present := middleware.GetImpersonator(r) == nil
+		if present {
+			EventService.Presence.Join(post.ID, viewer)
+			defer EventService.Presence.Leave(post.ID, user.ID)
+		}
+
+		done := make(chan struct{})
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			writeLiveThread(EventService, conn, subscription, r, user.ID, done)
+		}()
+
+		readLiveThread(EventService, conn, post.ID, viewer, present)
+		close(done)
+		<-written
+	}
+}
+
+// writeLiveThread forwards events to the client and pings it until the
+// client leaves, falls behind or its session ends
+func writeLiveThread(EventService *EventService, conn *websocket.Conn, subscription *events.Subscription, r *http.Request, viewerID string, done <-chan struct{}) {
+	ping := time.NewTicker(EventService.Config.Heartbeat)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case event, ok := <-subscription.Events:
+			if !ok {
+				conn.Close(websocket.CloseTryAgainLater, "Fell behind")
+				return
+			}
+			// Users see their own comments, but not themselves typing
+			if event.Type == config.EVENT_TYPING && event.AuthorID == viewerID {
+				continue
+			}
+			if hiddenFrom(EventService, event, viewerID) {
+				continue
+			}
+
+			message, err := json.Marshal(models.LiveMessage{Type: event.Type, Data: event.Data})
+			if err == nil {
+				err = conn.WriteText(message)
+			}
+			if err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+
+		case <-ping.C:
+			if !sessionAlive(EventService, r) {
+				conn.Close(websocket.ClosePolicyViolation, "Session ended")
+				return
+			}
+			if err := conn.Ping(); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// readLiveThread handles messages from the client until the connection
+// closes. Typing indicators are passed on at most every TYPING_INTERVAL.
+func readLiveThread(EventService *EventService, conn *websocket.Conn, postID string, viewer models.Viewer, present bool) {
+	var lastTyping time.Time
+
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		// Unknown and malformed messages are ignored
+		var message models.LiveMessage
+		if json.Unmarshal(data, &message) != nil {
+			continue
+		}
+
+		if message.Type == config.EVENT_TYPING && present && time.Since(lastTyping) >= config.TYPING_INTERVAL {
+			lastTyping = time.Now()
+			EventService.Hub.Broadcast(events.ThreadTopic(postID), config.EVENT_TYPING, viewer, viewer.UserID)
+		}
+	}
+}
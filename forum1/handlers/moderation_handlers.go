@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+)
+
+// ModerationService handles moderator actions against user accounts and deleted content
+type ModerationService struct {
+	UserRepo         *repository.UserRepository
+	SessionRepo      *repository.SessionRepository
+	SanctionRepo     *repository.SanctionRepository
+	SecurityRepo     *repository.SecurityEventRepository
+	TrashRepo        *repository.TrashRepository
+	NotificationRepo *repository.NotificationRepository
+	Events           *events.Hub
+	Config           config.ModerationConfig
+}
+
+// NewModerationService creates a new ModerationService
+func NewModerationService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, sanctionRepo *repository.SanctionRepository, securityRepo *repository.SecurityEventRepository, trashRepo *repository.TrashRepository, notificationRepo *repository.NotificationRepository, hub *events.Hub, cfg config.ModerationConfig) *ModerationService {
+	return &ModerationService{
+		UserRepo:         userRepo,
+		SessionRepo:      sessionRepo,
+		SanctionRepo:     sanctionRepo,
+		SecurityRepo:     securityRepo,
+		TrashRepo:        trashRepo,
+		NotificationRepo: notificationRepo,
+		Events:           hub,
+		Config:           cfg,
+	}
+}
+
+// UserSanctions handles GET (history) and POST (issue a sanction) on
+// /api/moderation/users/{username}/sanctions
+func UserSanctions(ModerationService *ModerationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		moderator := middleware.GetCurrentUser(r)
+
+		target, err := ModerationService.UserRepo.GetByUsername(r.PathValue("username"))
+		if err != nil {
+			switch err {
+			case config.ErrUserNotFound:
+				http.Error(w, "User not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeSanctionHistory(ModerationService, w, target)
+		case http.MethodPost:
+			issueSanction(ModerationService, w, r, moderator, target)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// issueSanction records a warning, suspension or ban and enforces it right away
+func issueSanction(ModerationService *ModerationService, w http.ResponseWriter, r *http.Request, moderator, target *models.User) {
+	// Parse request body
+	var req models.SanctionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Moderators cannot act on themselves or on staff of equal or higher rank
+	if !canModerate(moderator, target) {
+		http.Error(w, "You cannot sanction this user", http.StatusForbidden)
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > ModerationService.Config.MaxSanctionReason {
+		http.Error(w, fmt.Sprintf("Reason is required and must be at most %d characters long", ModerationService.Config.MaxSanctionReason), http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt *time.Time
+	switch req.SanctionType {
+	case config.SANCTION_WARNING, config.SANCTION_BAN:
+	case config.SANCTION_SUSPENSION:
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			http.Error(w, "Suspensions need a positive duration such as \"72h\"", http.StatusBadRequest)
+			return
+		}
+		until := time.Now().Add(duration)
+		expiresAt = &until
+	default:
+		http.Error(w, "Sanction type must be warning, suspension or ban", http.StatusBadRequest)
+		return
+	}
+
+	sanction, err := ModerationService.SanctionRepo.Create(target.ID, moderator.ID, req.SanctionType, req.Reason, expiresAt)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sanctions := []models.Sanction{*sanction}
+
+	// Too many recent warnings escalate to an automatic suspension
+	if req.SanctionType == config.SANCTION_WARNING {
+		escalation, err := escalateWarnings(ModerationService, moderator, target)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if escalation != nil {
+			sanctions = append(sanctions, *escalation)
+		}
+	}
+
+	for _, sanction := range sanctions {
+		notice := moderationNotice(moderator.ID, sanctionMessage(sanction))
+		notice.UserID = target.ID
+		deliver(ModerationService.Events)(ModerationService.NotificationRepo.Create(notice))
+	}
+
+	// Revoke existing sessions of suspended or banned users
+	if last := sanctions[len(sanctions)-1]; last.SanctionType != config.SANCTION_WARNING {
+		if _, err := ModerationService.SessionRepo.DeleteByUserID(target.ID); err != nil {
+			log.Printf("Failed to revoke sessions of user %s: %v", target.ID, err)
+		}
+		details := fmt.Sprintf("Signed out by a moderator (%s)", last.SanctionType)
+		if err := ModerationService.SecurityRepo.Record(target.ID, config.SECURITY_EVENT_SESSIONS_REVOKED, "", "", details); err != nil {
+			log.Printf("Failed to record session revocation of user %s: %v", target.ID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sanctions)
+}
+
+// escalateWarnings suspends a user once they reach the configured number of
+// warnings within the warning window
+func escalateWarnings(ModerationService *ModerationService, moderator, target *models.User) (*models.Sanction, error) {
+	cfg := ModerationService.Config
+	if cfg.WarningThreshold <= 0 {
+		return nil, nil
+	}
+
+	count, err := ModerationService.SanctionRepo.CountActiveWarnings(target.ID, time.Now().Add(-cfg.WarningWindow))
+	if err != nil {
+		return nil, err
+	}
+	if count < cfg.WarningThreshold {
+		return nil, nil
+	}
+
+	// Do not stack automatic suspensions on top of an existing restriction
+	restriction, err := ModerationService.SanctionRepo.GetActiveRestriction(target.ID)
+	if err != nil || restriction != nil {
+		return nil, err
+	}
+
+	until := time.Now().Add(cfg.AutoSuspension)
+	reason := fmt.Sprintf("Automatic suspension after %d warnings", count)
+	return ModerationService.SanctionRepo.Create(target.ID, moderator.ID, config.SANCTION_SUSPENSION, reason, &until)
+}
+
+// writeSanctionHistory returns every sanction of a user with their current state
+func writeSanctionHistory(ModerationService *ModerationService, w http.ResponseWriter, target *models.User) {
+	sanctions, err := ModerationService.SanctionRepo.ListByUserID(target.ID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	restriction, err := ModerationService.SanctionRepo.GetActiveRestriction(target.ID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	warnings, err := ModerationService.SanctionRepo.CountActiveWarnings(target.ID, time.Now().Add(-ModerationService.Config.WarningWindow))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.SanctionHistory{
+		User:           *target,
+		ActiveWarnings: warnings,
+		Restriction:    restriction,
+		Sanctions:      sanctions,
+	})
+}
+
+// RevokeSanction lifts a warning, suspension or ban early
+func RevokeSanction(ModerationService *ModerationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow DELETE requests
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		moderator := middleware.GetCurrentUser(r)
+
+		sanction, err := ModerationService.SanctionRepo.GetByID(r.PathValue("id"))
+		if err != nil {
+			switch err {
+			case config.ErrSanctionNotFound:
+				http.Error(w, "Sanction not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		target, err := ModerationService.UserRepo.GetByID(sanction.UserID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !canModerate(moderator, target) {
+			http.Error(w, "You cannot modify sanctions of this user", http.StatusForbidden)
+			return
+		}
+
+		err = ModerationService.SanctionRepo.Revoke(sanction.ID, moderator.ID)
+		if err != nil {
+			switch err {
+			case config.ErrSanctionNotFound:
+				http.Error(w, "Sanction already revoked", http.StatusConflict)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		notice := moderationNotice(moderator.ID, fmt.Sprintf("Your %s was revoked by a moderator", sanction.SanctionType))
+		notice.UserID = target.ID
+		deliver(ModerationService.Events)(ModerationService.NotificationRepo.Create(notice))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// sanctionMessage describes a sanction to the sanctioned user
+func sanctionMessage(sanction models.Sanction) string {
+	switch {
+	case sanction.SanctionType == config.SANCTION_SUSPENSION && sanction.ExpiresAt != nil:
+		return fmt.Sprintf("Your account is suspended until %s: %s", sanction.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"), sanction.Reason)
+	case sanction.SanctionType == config.SANCTION_BAN:
+		return "Your account is banned: " + sanction.Reason
+	default:
+		return "You received a warning: " + sanction.Reason
+	}
+}
+
+// canModerate reports whether a moderator may sanction the target user.
+// Admins can act on anyone but themselves; moderators only on regular users.
+func canModerate(moderator, target *models.User) bool {
+	if moderator.ID == target.ID || target.ID == config.DELETED_USER_ID {
+		return false
+	}
+	if moderator.Role == config.ROLE_ADMIN {
+		return true
+	}
+	return moderator.Role == config.ROLE_MODERATOR && target.Role == config.ROLE_USER
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// NotificationService handles a user's notifications and notification preferences
+type NotificationService struct {
+	NotificationRepo *repository.NotificationRepository
+}
+
+// NewNotificationService creates a new NotificationService
+func NewNotificationService(notificationRepo *repository.NotificationRepository) *NotificationService {
+	return &NotificationService{
+		NotificationRepo: notificationRepo,
+	}
+}
+
+// Notifications lists the current user's notifications, newest first.
+// "unread=true" leaves out notifications that were already read.
+func Notifications(NotificationService *NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		limit, offset := utils.ParsePagination(r)
+		unreadOnly := r.URL.Query().Get("unread") == "true"
+
+		notifications, err := NotificationService.NotificationRepo.List(user.ID, unreadOnly, limit, offset)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(notifications)
+	}
+}
+
+// UnreadNotificationCount returns how many notifications the current user has not read
+func UnreadNotificationCount(NotificationService *NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		count, err := NotificationService.NotificationRepo.CountUnread(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.UnreadCount{Unread: count})
+	}
+}
+
+// MarkNotificationRead marks one of the current user's notifications as read
+func MarkNotificationRead(NotificationService *NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		err := NotificationService.NotificationRepo.MarkRead(r.PathValue("id"), user.ID)
+		if err != nil {
+			switch err {
+			case config.ErrNotificationNotFound:
+				http.Error(w, "Notification not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MarkAllNotificationsRead marks every notification of the current user as read
+func MarkAllNotificationsRead(NotificationService *NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+		if _, err := NotificationService.NotificationRepo.MarkAllRead(user.ID); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// NotificationPreferences handles GET and PUT on /api/notifications/preferences.
+// PUT only changes the types it mentions; moderator notices cannot be turned off.
+func NotificationPreferences(NotificationService *NotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			// Parse request body
+			var preferences models.NotificationPreferences
+			err := json.NewDecoder(r.Body).Decode(&preferences)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			for notificationType, enabled := range preferences {
+				if !slices.Contains(config.NOTIFICATION_TYPES, notificationType) {
+					http.Error(w, fmt.Sprintf("Unknown notification type %q", notificationType), http.StatusBadRequest)
+					return
+				}
+				if notificationType == config.NOTIFICATION_MODERATION && !enabled {
+					http.Error(w, "Moderation notifications cannot be turned off", http.StatusBadRequest)
+					return
+				}
+			}
+
+			if err = NotificationService.NotificationRepo.SetPreferences(user.ID, preferences); err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		preferences, err := NotificationService.NotificationRepo.GetPreferences(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preferences)
+	}
+}
+
+// deliver returns a function taking the result of recording a notification,
+// which pushes it to the recipient's open event streams. Notifications are a
+// side effect, so failures are logged and never fail the request.
+func deliver(hub *events.Hub) func(notification *models.Notification, err error) {
+	return func(notification *models.Notification, err error) {
+		if err != nil {
+			log.Printf("Failed to record notification: %v", err)
+			return
+		}
+		if notification != nil {
+			hub.Publish(events.UserTopic(notification.UserID), config.EVENT_NOTIFICATION, notification, "")
+		}
+	}
+}
+
+// notifyReply tells the author of the comment replied to, and the author of
+// the post, about a new comment. Someone who wrote both is notified once. It
+// returns the users it tried to notify.
+func notifyReply(PostService *PostService, comment *models.Comment) []string {
+	replyNotification := func(recipientID, notificationType string) models.Notification {
+		return models.Notification{
+			UserID:    recipientID,
+			Type:      notificationType,
+			ActorID:   &comment.UserID,
+			PostID:    &comment.PostID,
+			CommentID: &comment.ID,
+		}
+	}
+
+	var recipients []string
+	if comment.ReplyTo != nil {
+		parent, err := PostService.CommentRepo.GetByID(*comment.ReplyTo)
+		if err != nil {
+			deliver(PostService.Events)(nil, err)
+			return recipients
+		}
+		recipients = append(recipients, parent.UserID)
+		deliver(PostService.Events)(PostService.NotificationRepo.Create(replyNotification(parent.UserID, config.NOTIFICATION_REPLY_TO_COMMENT)))
+	}
+
+	post, err := PostService.PostRepo.GetByID(comment.PostID)
+	if err != nil {
+		deliver(PostService.Events)(nil, err)
+		return recipients
+	}
+	if !slices.Contains(recipients, post.UserID) {
+		recipients = append(recipients, post.UserID)
+		deliver(PostService.Events)(PostService.NotificationRepo.Create(replyNotification(post.UserID, config.NOTIFICATION_REPLY_TO_POST)))
+	}
+	return recipients
+}
+
+// recordMentions records the users mentioned in the post, comment or draft
+// in targetColumn. It returns their usernames and the IDs of the users
+// mentioned for the first time. Mentions are a side effect, so failures are
+// logged and never fail the request.
+func recordMentions(PostService *PostService, targetColumn, targetID, authorID, content string) ([]string, []string) {
+	usernames, added, err := PostService.MentionRepo.Sync(targetColumn, targetID, authorID, content)
+	if err != nil {
+		log.Printf("Failed to record mentions: %v", err)
+	}
+	return usernames, added
+}
+
+// notifyMentions tells the users in userIDs they were mentioned in the post
+// or comment in targetColumn, except those in notified, who already heard
+// about it another way
+func notifyMentions(PostService *PostService, targetColumn, targetID string, userIDs, notified []string) {
+	deliverMentions(PostService.NotificationRepo, PostService.Events, targetColumn, targetID, userIDs, notified)
+}
+
+// NotifyPublished tells everyone a newly published post mentions about it,
+// whether it was published by hand or on schedule
+func NotifyPublished(mentionRepo *repository.MentionRepository, notificationRepo *repository.NotificationRepository, hub *events.Hub, postID string) {
+	mentioned, err := mentionRepo.ListMentioned("post_id", postID)
+	if err != nil {
+		log.Printf("Failed to list mentions of post %s: %v", postID, err)
+		return
+	}
+	deliverMentions(notificationRepo, hub, "post_id", postID, mentioned, nil)
+}
+
+// deliverMentions records and streams a mention notification for each user
+// in userIDs not in notified
+func deliverMentions(notificationRepo *repository.NotificationRepository, hub *events.Hub, targetColumn, targetID string, userIDs, notified []string) {
+	for _, userID := range userIDs {
+		if slices.Contains(notified, userID) {
+			continue
+		}
+		deliver(hub)(notificationRepo.CreateForMention(userID, targetColumn, targetID))
+	}
+}
+
+// notifyReaction tells the author of the post or comment in targetColumn
+// about a reaction, or withdraws the unread notification once the reaction
+// is taken back (reactionType 0)
+func notifyReaction(PostService *PostService, targetColumn, targetID, actorID string, reactionType int) {
+	if reactionType == 0 {
+		deliver(PostService.Events)(nil, PostService.NotificationRepo.RemoveUnreadReaction(actorID, targetColumn, targetID))
+		return
+	}
+
+	notification := models.Notification{
+		Type:         config.NOTIFICATION_REACTION,
+		ActorID:      &actorID,
+		ReactionType: reactionType,
+	}
+	if targetColumn == "post_id" {
+		deliver(PostService.Events)(PostService.NotificationRepo.CreateForPost(targetID, notification))
+	} else {
+		deliver(PostService.Events)(PostService.NotificationRepo.CreateForComment(targetID, notification))
+	}
+}
+
+// moderationNotice builds a notification about a moderator's action. The
+// moderator is recorded but not shown to the recipient.
+func moderationNotice(moderatorID, message string) models.Notification {
+	return models.Notification{
+		Type:    config.NOTIFICATION_MODERATION,
+		ActorID: &moderatorID,
+		Message: message,
+	}
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// PostService handles post, comment and reaction requests
+type PostService struct {
+	PostRepo         *repository.PostRepository
+	CommentRepo      *repository.CommentRepository
+	ReactionRepo     *repository.ReactionRepository
+	UploadRepo       *repository.UploadRepository
+	RevisionRepo     *repository.RevisionRepository
+	TrashRepo        *repository.TrashRepository
+	DraftRepo        *repository.DraftRepository
+	MentionRepo      *repository.MentionRepository
+	BookmarkRepo     *repository.BookmarkRepository
+	NotificationRepo *repository.NotificationRepository
+	Events           *events.Hub
+}
+
+// NewPostService creates a new PostService
+func NewPostService(postRepo *repository.PostRepository, commentRepo *repository.CommentRepository, reactionRepo *repository.ReactionRepository, uploadRepo *repository.UploadRepository, revisionRepo *repository.RevisionRepository, trashRepo *repository.TrashRepository, draftRepo *repository.DraftRepository, mentionRepo *repository.MentionRepository, bookmarkRepo *repository.BookmarkRepository, notificationRepo *repository.NotificationRepository, hub *events.Hub) *PostService {
+	return &PostService{
+		PostRepo:         postRepo,
+		CommentRepo:      commentRepo,
+		ReactionRepo:     reactionRepo,
+		UploadRepo:       uploadRepo,
+		RevisionRepo:     revisionRepo,
+		TrashRepo:        trashRepo,
+		DraftRepo:        draftRepo,
+		MentionRepo:      mentionRepo,
+		BookmarkRepo:     bookmarkRepo,
+		NotificationRepo: notificationRepo,
+		Events:           hub,
+	}
+}
+
+// viewerID returns the current user's ID, or "" for anonymous visitors
+func viewerID(r *http.Request) string {
+	if user := middleware.GetCurrentUser(r); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// ListCategories returns every post category
+func ListCategories(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		categories, err := PostService.PostRepo.ListCategories()
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(categories)
+	}
+}
+
+// Posts handles GET (feed) and POST (create) on /api/posts
+func Posts(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			limit, offset := utils.ParsePagination(r)
+			posts, err := PostService.PostRepo.List(viewerID(r), r.URL.Query().Get("category"), limit, offset)
+			if err == nil {
+				err = loadPostAttachments(PostService.UploadRepo, posts)
+			}
+			if err == nil {
+				err = loadPostsBookmarked(PostService.BookmarkRepo, viewerID(r), posts)
+			}
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(posts)
+
+		case http.MethodPost:
+			user := middleware.GetCurrentUser(r)
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// Parse request body
+			var creation models.PostCreation
+			err := json.NewDecoder(r.Body).Decode(&creation)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			// validate content
+			err = utils.ValidateContent(creation.Content, config.MAX_POST_LEN)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			attachments, ok := checkAttachments(w, PostService.UploadRepo, user.ID, creation.Attachments)
+			if !ok {
+				return
+			}
+
+			post, err := PostService.PostRepo.Create(user.ID, creation, attachments)
+			if err != nil {
+				switch err {
+				case config.ErrCategoryNotFound:
+					http.Error(w, "Category not found", http.StatusBadRequest)
+				default:
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+
+			if len(attachments) > 0 {
+				posts := []models.Post{*post}
+				err = loadPostAttachments(PostService.UploadRepo, posts)
+				if err != nil {
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				post = &posts[0]
+			}
+
+			mentioned, added := recordMentions(PostService, "post_id", post.ID, user.ID, post.Content)
+			post.ContentHTML = utils.RenderMarkdown(post.Content, mentioned)
+			notifyMentions(PostService, "post_id", post.ID, added, nil)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(post)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// Post handles GET (thread), PUT (edit) and DELETE on /api/posts/{id}
+func Post(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getThread(PostService, w, r)
+
+		case http.MethodPut:
+			user := middleware.GetCurrentUser(r)
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			editPost(PostService, w, r, user)
+
+		case http.MethodDelete:
+			user := middleware.GetCurrentUser(r)
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			deletePost(PostService, w, r, user)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// getThread returns a post with the comments visible to the current user
+func getThread(PostService *PostService, w http.ResponseWriter, r *http.Request) {
+	post, err := PostService.PostRepo.GetByID(r.PathValue("id"))
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	// Attachments are loaded into a slice holding the post
+	posts := []models.Post{*post}
+	comments, err := PostService.CommentRepo.ListByPost(post.ID, viewerID(r))
+	if err == nil {
+		err = loadPostAttachments(PostService.UploadRepo, posts)
+	}
+	if err == nil {
+		err = loadCommentAttachments(PostService.UploadRepo, comments)
+	}
+	if err == nil {
+		err = loadPostsBookmarked(PostService.BookmarkRepo, viewerID(r), posts)
+	}
+	if err == nil {
+		err = loadCommentsBookmarked(PostService.BookmarkRepo, viewerID(r), comments)
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Thread{Post: posts[0], Comments: comments})
+}
+
+// CreateComment adds a comment to a post
+func CreateComment(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		// Parse request body
+		var creation models.CommentCreation
+		err := json.NewDecoder(r.Body).Decode(&creation)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// validate content
+		err = utils.ValidateContent(creation.Content, config.MAX_COMMENT_LEN)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		attachments, ok := checkAttachments(w, PostService.UploadRepo, user.ID, creation.Attachments)
+		if !ok {
+			return
+		}
+
+		comment, err := PostService.CommentRepo.Create(r.PathValue("id"), user.ID, creation.Content, creation.ReplyTo, attachments)
+		if err != nil {
+			writePostError(w, err)
+			return
+		}
+
+		if len(attachments) > 0 {
+			comments := []models.Comment{*comment}
+			err = loadCommentAttachments(PostService.UploadRepo, comments)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			comment = &comments[0]
+		}
+
+		mentioned, added := recordMentions(PostService, "comment_id", comment.ID, user.ID, comment.Content)
+		comment.ContentHTML = utils.RenderMarkdown(comment.Content, mentioned)
+
+		PostService.Events.Publish(events.PostTopic(comment.PostID), config.EVENT_COMMENT, comment, comment.UserID)
+		notifyMentions(PostService, "comment_id", comment.ID, added, notifyReply(PostService, comment))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(comment)
+	}
+}
+
+// PreviewContent renders Markdown exactly as it would appear once posted,
+// mentions included
+func PreviewContent(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Parse request body
+		var preview models.MarkdownPreview
+		err := json.NewDecoder(r.Body).Decode(&preview)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Posts allow the longest content
+		err = utils.ValidateContent(preview.Content, config.MAX_POST_LEN)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mentioned, err := PostService.MentionRepo.Resolve(viewerID(r), preview.Content)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.RenderedContent{HTML: utils.RenderMarkdown(preview.Content, mentioned)})
+	}
+}
+
+// ReactToPost likes or dislikes a post
+func ReactToPost(PostService *PostService) http.HandlerFunc {
+	return reactHandler(PostService, PostService.ReactionRepo.ReactToPost, "post_id")
+}
+
+// ReactToComment likes or dislikes a comment
+func ReactToComment(PostService *PostService) http.HandlerFunc {
+	return reactHandler(PostService, PostService.ReactionRepo.ReactToComment, "comment_id")
+}
+
+// reactHandler builds a reaction handler around a repository method for the
+// post or comment in targetColumn
+func reactHandler(PostService *PostService, react func(targetID, userID string, reactionType int) (*models.ReactionCounts, error), targetColumn string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		// Parse request body
+		var req models.ReactionRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.ReactionType != config.REACTION_LIKE && req.ReactionType != config.REACTION_DISLIKE {
+			http.Error(w, "Reaction type must be 1 (like) or 2 (dislike)", http.StatusBadRequest)
+			return
+		}
+
+		counts, err := react(r.PathValue("id"), user.ID, req.ReactionType)
+		if err != nil {
+			writePostError(w, err)
+			return
+		}
+
+		publishReactions(PostService, targetColumn, r.PathValue("id"), counts)
+		notifyReaction(PostService, targetColumn, r.PathValue("id"), user.ID, counts.Mine)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(counts)
+	}
+}
+
+// publishReactions streams the new reaction totals of a post or comment to
+// the viewers of its thread
+func publishReactions(PostService *PostService, targetColumn, targetID string, counts *models.ReactionCounts) {
+	update := models.ReactionUpdate{PostID: targetID, Likes: counts.Likes, Dislikes: counts.Dislikes}
+	if targetColumn == "comment_id" {
+		comment, err := PostService.CommentRepo.GetByID(targetID)
+		if err != nil {
+			log.Printf("Failed to look up comment %s: %v", targetID, err)
+			return
+		}
+		update.PostID = comment.PostID
+		update.CommentID = &comment.ID
+	}
+
+	PostService.Events.Publish(events.PostTopic(update.PostID), config.EVENT_REACTIONS, update, "")
+}
+
+// writePostError maps content repository errors to HTTP responses
+func writePostError(w http.ResponseWriter, err error) {
+	switch err {
+	case config.ErrPostNotFound:
+		http.Error(w, "Post not found", http.StatusNotFound)
+	case config.ErrCommentNotFound:
+		http.Error(w, "Comment not found", http.StatusNotFound)
+	case config.ErrReplyTargetNotFound:
+		http.Error(w, "The comment replied to is not part of this post", http.StatusBadRequest)
+	case config.ErrRevisionNotFound:
+		http.Error(w, "Revision not found", http.StatusNotFound)
+	case config.ErrBlocked:
+		http.Error(w, "You cannot interact with this user's content", http.StatusForbidden)
+	case config.ErrBookmarkNotFound:
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+)
+
+// RelationshipService handles blocking and muting other users
+type RelationshipService struct {
+	UserRepo         *repository.UserRepository
+	RelationshipRepo *repository.RelationshipRepository
+}
+
+// NewRelationshipService creates a new RelationshipService
+func NewRelationshipService(userRepo *repository.UserRepository, relationshipRepo *repository.RelationshipRepository) *RelationshipService {
+	return &RelationshipService{
+		UserRepo:         userRepo,
+		RelationshipRepo: relationshipRepo,
+	}
+}
+
+// BlockUser handles POST (block) and DELETE (unblock) on /api/users/{username}/block
+func BlockUser(RelationshipService *RelationshipService) http.HandlerFunc {
+	return relationHandler(RelationshipService, RelationshipService.RelationshipRepo.Block, RelationshipService.RelationshipRepo.Unblock)
+}
+
+// MuteUser handles POST (mute) and DELETE (unmute) on /api/users/{username}/mute
+func MuteUser(RelationshipService *RelationshipService) http.HandlerFunc {
+	return relationHandler(RelationshipService, RelationshipService.RelationshipRepo.Mute, RelationshipService.RelationshipRepo.Unmute)
+}
+
+// relationHandler builds a handler that adds or removes a relation to the user in the path
+func relationHandler(RelationshipService *RelationshipService, add, remove func(userID, targetID string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		target, err := RelationshipService.UserRepo.GetByUsername(r.PathValue("username"))
+		if err != nil {
+			switch err {
+			case config.ErrUserNotFound:
+				http.Error(w, "User not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			err = add(user.ID, target.ID)
+		case http.MethodDelete:
+			err = remove(user.ID, target.ID)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err != nil {
+			switch err {
+			case config.ErrInvalidRelation:
+				http.Error(w, "You cannot block or mute yourself", http.StatusBadRequest)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListBlockedUsers returns the users the current user has blocked
+func ListBlockedUsers(RelationshipService *RelationshipService) http.HandlerFunc {
+	return relationListHandler(RelationshipService.RelationshipRepo.ListBlocked)
+}
+
+// ListMutedUsers returns the users the current user has muted
+func ListMutedUsers(RelationshipService *RelationshipService) http.HandlerFunc {
+	return relationListHandler(RelationshipService.RelationshipRepo.ListMuted)
+}
+
+// relationListHandler builds a handler listing the current user's relations
+func relationListHandler(list func(userID string) ([]models.UserRelation, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		relations, err := list(middleware.GetCurrentUser(r).ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(relations)
+	}
+}
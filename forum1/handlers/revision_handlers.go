@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// editPost lets the author of a post change its content
+func editPost(PostService *PostService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	post, err := PostService.PostRepo.GetByID(r.PathValue("id"))
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+	if post.UserID != user.ID {
+		http.Error(w, "You can only edit your own posts", http.StatusForbidden)
+		return
+	}
+
+	edit, ok := decodeContentEdit(w, r, config.MAX_POST_LEN)
+	if !ok {
+		return
+	}
+
+	err = PostService.RevisionRepo.EditPost(post.ID, user.ID, edit.Content, edit.Reason)
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	postEdited(PostService, post.ID)
+	writePost(PostService, w, post.ID)
+}
+
+// Comment handles PUT (edit) and DELETE on /api/comments/{id}
+func Comment(PostService *PostService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodPut:
+			editComment(PostService, w, r, user)
+		case http.MethodDelete:
+			deleteComment(PostService, w, r, user)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// editComment lets the author of a comment change its content
+func editComment(PostService *PostService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	comment, err := PostService.CommentRepo.GetByID(r.PathValue("id"))
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+	if comment.UserID != user.ID {
+		http.Error(w, "You can only edit your own comments", http.StatusForbidden)
+		return
+	}
+
+	edit, ok := decodeContentEdit(w, r, config.MAX_COMMENT_LEN)
+	if !ok {
+		return
+	}
+
+	err = PostService.RevisionRepo.EditComment(comment.ID, user.ID, edit.Content, edit.Reason)
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	commentEdited(PostService, comment.ID)
+	writeComment(PostService, w, comment.ID)
+}
+
+// decodeContentEdit parses and validates an edit. It writes the error
+// response and returns false when the edit is not acceptable.
+func decodeContentEdit(w http.ResponseWriter, r *http.Request, maxLen int) (*models.ContentEdit, bool) {
+	// Parse request body
+	var edit models.ContentEdit
+	err := json.NewDecoder(r.Body).Decode(&edit)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// validate content
+	err = utils.ValidateContent(edit.Content, maxLen)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if utf8.RuneCountInString(edit.Reason) > config.MAX_EDIT_REASON_LEN {
+		http.Error(w, fmt.Sprintf("Edit reason must be at most %d characters long", config.MAX_EDIT_REASON_LEN), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return &edit, true
+}
+
+// PostRevisions returns the edit history of a post
+func PostRevisions(PostService *PostService) http.HandlerFunc {
+	return revisionsHandler(PostService.RevisionRepo.ListForPost)
+}
+
+// CommentRevisions returns the edit history of a comment
+func CommentRevisions(PostService *PostService) http.HandlerFunc {
+	return revisionsHandler(PostService.RevisionRepo.ListForComment)
+}
+
+// revisionsHandler builds a handler listing every revision, oldest first,
+// each with a line-level diff from the one before. With "from" and "to"
+// parameters it returns the diff between those two revisions instead.
+func revisionsHandler(list func(id string) ([]models.Revision, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		revisions, err := list(r.PathValue("id"))
+		if err != nil {
+			writePostError(w, err)
+			return
+		}
+
+		params := r.URL.Query()
+		if params.Has("from") || params.Has("to") {
+			from, errFrom := findRevision(revisions, params.Get("from"))
+			to, errTo := findRevision(revisions, params.Get("to"))
+			if errFrom != nil || errTo != nil {
+				http.Error(w, "Revision not found", http.StatusNotFound)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.RevisionDiff{
+				From: from.Number,
+				To:   to.Number,
+				Diff: utils.DiffLines(from.Content, to.Content),
+			})
+			return
+		}
+
+		for i := 1; i < len(revisions); i++ {
+			revisions[i].Diff = utils.DiffLines(revisions[i-1].Content, revisions[i].Content)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(revisions)
+	}
+}
+
+// RestorePostRevision lets moderators put an earlier revision of a post back
+func RestorePostRevision(PostService *PostService) http.HandlerFunc {
+	return restoreHandler(PostService.RevisionRepo.ListForPost, PostService.RevisionRepo.EditPost, func(w http.ResponseWriter, id string) {
+		postEdited(PostService, id)
+		writePost(PostService, w, id)
+	}, PostService.NotificationRepo.CreateForPost, PostService.Events, "post")
+}
+
+// RestoreCommentRevision lets moderators put an earlier revision of a comment back
+func RestoreCommentRevision(PostService *PostService) http.HandlerFunc {
+	return restoreHandler(PostService.RevisionRepo.ListForComment, PostService.RevisionRepo.EditComment, func(w http.ResponseWriter, id string) {
+		commentEdited(PostService, id)
+		writeComment(PostService, w, id)
+	}, PostService.NotificationRepo.CreateForComment, PostService.Events, "comment")
+}
+
+// restoreHandler builds a handler saving the content of an earlier revision
+// as a new revision, so the restore itself shows up in the history, and lets
+// the author know
+func restoreHandler(
+	list func(id string) ([]models.Revision, error),
+	edit func(id, editorID, content, reason string) error,
+	write func(w http.ResponseWriter, id string),
+	notifyAuthor func(id string, notification models.Notification) (*models.Notification, error),
+	hub *events.Hub,
+	kind string,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		moderator := middleware.GetCurrentUser(r)
+		id := r.PathValue("id")
+
+		// Parse request body; the reason is optional
+		var restore models.RevisionRestore
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&restore); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		revisions, err := list(id)
+		if err != nil {
+			writePostError(w, err)
+			return
+		}
+
+		revision, err := findRevision(revisions, r.PathValue("number"))
+		if err != nil {
+			writePostError(w, err)
+			return
+		}
+
+		reason := fmt.Sprintf("Restored revision %d", revision.Number)
+		if restore.Reason != "" {
+			reason += ": " + restore.Reason
+		}
+		if utf8.RuneCountInString(reason) > config.MAX_EDIT_REASON_LEN {
+			http.Error(w, fmt.Sprintf("Edit reason must be at most %d characters long", config.MAX_EDIT_REASON_LEN), http.StatusBadRequest)
+			return
+		}
+
+		if err = edit(id, moderator.ID, revision.Content, reason); err != nil {
+			writePostError(w, err)
+			return
+		}
+
+		message := fmt.Sprintf("A moderator restored revision %d of your %s", revision.Number, kind)
+		if restore.Reason != "" {
+			message += ": " + restore.Reason
+		}
+		deliver(hub)(notifyAuthor(id, moderationNotice(moderator.ID, message)))
+
+		write(w, id)
+	}
+}
+
+// findRevision looks up a revision by its number as given in a request
+func findRevision(revisions []models.Revision, number string) (*models.Revision, error) {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, config.ErrRevisionNotFound
+	}
+
+	for i := range revisions {
+		if revisions[i].Number == n {
+			return &revisions[i], nil
+		}
+	}
+	return nil, config.ErrRevisionNotFound
+}
+
+// writePost responds with a post and its attachments
+func writePost(PostService *PostService, w http.ResponseWriter, postID string) {
+	post, err := PostService.PostRepo.GetByID(postID)
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	posts := []models.Post{*post}
+	if err := loadPostAttachments(PostService.UploadRepo, posts); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts[0])
+}
+
+// writeComment responds with a comment and its attachments
+func writeComment(PostService *PostService, w http.ResponseWriter, commentID string) {
+	comment, err := PostService.CommentRepo.GetByID(commentID)
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	comments := []models.Comment{*comment}
+	if err := loadCommentAttachments(PostService.UploadRepo, comments); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments[0])
+}
+
+// postEdited records the mentions of an edited post, notifying the users it
+// now mentions for the first time, and streams the post to the viewers of its
+// thread
+func postEdited(PostService *PostService, postID string) {
+	post, err := PostService.PostRepo.GetByID(postID)
+	posts := []models.Post{}
+	if err == nil {
+		posts = append(posts, *post)
+		err = loadPostAttachments(PostService.UploadRepo, posts)
+	}
+	if err != nil {
+		log.Printf("Failed to publish edit of post %s: %v", postID, err)
+		return
+	}
+
+	mentioned, added := recordMentions(PostService, "post_id", postID, post.UserID, post.Content)
+	posts[0].ContentHTML = utils.RenderMarkdown(post.Content, mentioned)
+	notifyMentions(PostService, "post_id", postID, added, nil)
+
+	PostService.Events.Publish(events.PostTopic(postID), config.EVENT_POST_EDITED, posts[0], post.UserID)
+}
+
+// commentEdited records the mentions of an edited comment, notifying the
+// users it now mentions for the first time, and streams the comment to the
+// viewers of its thread
+func commentEdited(PostService *PostService, commentID string) {
+	comment, err := PostService.CommentRepo.GetByID(commentID)
+	comments := []models.Comment{}
+	if err == nil {
+		comments = append(comments, *comment)
+		err = loadCommentAttachments(PostService.UploadRepo, comments)
+	}
+	if err != nil {
+		log.Printf("Failed to publish edit of comment %s: %v", commentID, err)
+		return
+	}
+
+	mentioned, added := recordMentions(PostService, "comment_id", commentID, comment.UserID, comment.Content)
+	comments[0].ContentHTML = utils.RenderMarkdown(comment.Content, mentioned)
+	notifyMentions(PostService, "comment_id", commentID, added, nil)
+
+	PostService.Events.Publish(events.PostTopic(comment.PostID), config.EVENT_COMMENT_EDITED, comments[0], comment.UserID)
+}
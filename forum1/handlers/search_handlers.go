@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// SearchService handles full-text search requests
+type SearchService struct {
+	SearchRepo *repository.SearchRepository
+}
+
+// NewSearchService creates a new SearchService
+func NewSearchService(searchRepo *repository.SearchRepository) *SearchService {
+	return &SearchService{SearchRepo: searchRepo}
+}
+
+// Search returns posts and comments matching the "q" parameter. Results can
+// be narrowed with "type" (posts or comments), "category", "author" and a
+// "from"/"to" date range (YYYY-MM-DD, both inclusive).
+func Search(SearchService *SearchService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		params := r.URL.Query()
+
+		q := params.Get("q")
+		if len(q) > config.MAX_SEARCH_QUERY_LEN {
+			http.Error(w, "Search query is too long", http.StatusBadRequest)
+			return
+		}
+		query := models.SearchQuery{
+			Match:      utils.BuildSearchQuery(q),
+			CategoryID: params.Get("category"),
+			Author:     params.Get("author"),
+		}
+		if query.Match == "" {
+			http.Error(w, "Search query is required", http.StatusBadRequest)
+			return
+		}
+
+		switch params.Get("type") {
+		case "":
+		case "posts":
+			query.Type = "post"
+		case "comments":
+			query.Type = "comment"
+		default:
+			http.Error(w, "Type must be posts or comments", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		query.From, err = parseSearchDate(params.Get("from"), false)
+		if err != nil {
+			http.Error(w, "From must be a date such as 2024-01-31", http.StatusBadRequest)
+			return
+		}
+		query.To, err = parseSearchDate(params.Get("to"), true)
+		if err != nil {
+			http.Error(w, "To must be a date such as 2024-01-31", http.StatusBadRequest)
+			return
+		}
+
+		query.Limit, query.Offset = utils.ParsePagination(r)
+
+		results, err := SearchService.SearchRepo.Search(query, viewerID(r))
+		if err != nil {
+			switch err {
+			case config.ErrSearchUnavailable:
+				http.Error(w, "Search is not available", http.StatusServiceUnavailable)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+// parseSearchDate parses a YYYY-MM-DD date filter. End dates are moved to
+// the following midnight so the whole day is included.
+func parseSearchDate(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		date = date.AddDate(0, 0, 1)
+	}
+	return &date, nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// SecurityEvents returns a page of the current user's security log, newest first
+func SecurityEvents(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		limit, offset := utils.ParsePagination(r)
+		events, err := UserService.SecurityRepo.ListByUserID(middleware.GetCurrentUser(r).ID, limit, offset)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+// recordSecurityEvent adds an event for the request's client to a user's
+// security log. Failures are logged but never fail the request.
+func recordSecurityEvent(securityRepo *repository.SecurityEventRepository, r *http.Request, userID, eventType, details string) {
+	if err := securityRepo.Record(userID, eventType, utils.ClientIP(r), r.UserAgent(), details); err != nil {
+		log.Printf("Failed to record %s event for user %s: %v", eventType, userID, err)
+	}
+}
+
+// alertNewDevice remembers the device of a successful login and emails the
+// user when it has not been seen on their account before. It reports
+// whether the device was new.
+func alertNewDevice(securityRepo *repository.SecurityEventRepository, r *http.Request, user *models.User, enabled bool) bool {
+	isNew, isFirst, err := securityRepo.RememberDevice(user.ID, utils.DeviceFingerprint(r), r.UserAgent())
+	if err != nil {
+		log.Printf("Failed to remember device for user %s: %v", user.ID, err)
+		return false
+	}
+	if !enabled || !isNew || isFirst {
+		return isNew
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Your account at %s was just accessed from a new device.\n\n"+
+		"Time: %s\nIP address: %s\nBrowser: %s\n\n"+
+		"If this was you, there is nothing to do. If not, change your password right away and review your security events.\n",
+		user.Username, utils.SiteURL(), time.Now().UTC().Format(time.RFC1123), utils.ClientIP(r), r.UserAgent())
+	utils.SendMailAsync(user.Email, "New login to your account", body)
+	return true
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// deletePost moves a post to the trash. Authors can delete their own posts;
+// moderators and admins can delete any post but must give a reason.
+func deletePost(PostService *PostService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	post, err := PostService.PostRepo.GetByID(r.PathValue("id"))
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	reason, ok := decodeDeletion(w, r, user, post.UserID)
+	if !ok {
+		return
+	}
+
+	if err = PostService.TrashRepo.DeletePost(post.ID, user.ID, reason); err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	notice := moderationNotice(user.ID, "Your post was deleted by a moderator: "+reason)
+	deliver(PostService.Events)(PostService.NotificationRepo.CreateForPost(post.ID, notice))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteComment moves a comment to the trash, with the same rules as posts
+func deleteComment(PostService *PostService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	comment, err := PostService.CommentRepo.GetByID(r.PathValue("id"))
+	if err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	reason, ok := decodeDeletion(w, r, user, comment.UserID)
+	if !ok {
+		return
+	}
+
+	if err = PostService.TrashRepo.DeleteComment(comment.ID, user.ID, reason); err != nil {
+		writePostError(w, err)
+		return
+	}
+
+	notice := moderationNotice(user.ID, "Your comment was deleted by a moderator: "+reason)
+	deliver(PostService.Events)(PostService.NotificationRepo.CreateForComment(comment.ID, notice))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeDeletion checks the user may delete content by authorID and returns
+// the deletion reason. It writes the error response and returns false when
+// the deletion is not allowed.
+func decodeDeletion(w http.ResponseWriter, r *http.Request, user *models.User, authorID string) (string, bool) {
+	isModerator := user.Role == config.ROLE_MODERATOR || user.Role == config.ROLE_ADMIN
+	if user.ID != authorID && !isModerator {
+		http.Error(w, "You can only delete your own content", http.StatusForbidden)
+		return "", false
+	}
+
+	// Parse request body; authors do not need to give a reason
+	var deletion models.ContentDeletion
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&deletion); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return "", false
+		}
+	}
+
+	if user.ID != authorID && deletion.Reason == "" {
+		http.Error(w, "A reason is required to delete other users' content", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(deletion.Reason) > config.MAX_EDIT_REASON_LEN {
+		http.Error(w, fmt.Sprintf("Reason must be at most %d characters long", config.MAX_EDIT_REASON_LEN), http.StatusBadRequest)
+		return "", false
+	}
+
+	return deletion.Reason, true
+}
+
+// Trash lists deleted posts and comments that can still be restored. The
+// "type" parameter narrows it to "posts" or "comments".
+func Trash(ModerationService *ModerationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var kind string
+		switch r.URL.Query().Get("type") {
+		case "":
+		case "posts":
+			kind = "post"
+		case "comments":
+			kind = "comment"
+		default:
+			http.Error(w, "Type must be posts or comments", http.StatusBadRequest)
+			return
+		}
+
+		limit, offset := utils.ParsePagination(r)
+		items, err := ModerationService.TrashRepo.List(kind, ModerationService.Config.TrashRetention, limit, offset)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	}
+}
+
+// RestorePost takes a post out of the trash
+func RestorePost(ModerationService *ModerationService) http.HandlerFunc {
+	return trashRestoreHandler(ModerationService, ModerationService.TrashRepo.RestorePost, ModerationService.NotificationRepo.CreateForPost, "post")
+}
+
+// RestoreComment takes a comment out of the trash
+func RestoreComment(ModerationService *ModerationService) http.HandlerFunc {
+	return trashRestoreHandler(ModerationService, ModerationService.TrashRepo.RestoreComment, ModerationService.NotificationRepo.CreateForComment, "comment")
+}
+
+// trashRestoreHandler builds a handler restoring the trashed item in the
+// path and letting its author know
+func trashRestoreHandler(
+	ModerationService *ModerationService,
+	restore func(id string, retention time.Duration) error,
+	notifyAuthor func(id string, notification models.Notification) (*models.Notification, error),
+	kind string,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		moderator := middleware.GetCurrentUser(r)
+
+		err := restore(r.PathValue("id"), ModerationService.Config.TrashRetention)
+		if err != nil {
+			switch err {
+			case config.ErrPostNotFound:
+				http.Error(w, "Post not found in the trash", http.StatusNotFound)
+			case config.ErrCommentNotFound:
+				http.Error(w, "Comment not found in the trash", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		deliver(ModerationService.Events)(notifyAuthor(r.PathValue("id"), moderationNotice(moderator.ID, fmt.Sprintf("Your %s was restored by a moderator", kind))))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
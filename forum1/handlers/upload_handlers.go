@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/storage"
+	"forum/utils"
+)
+
+// UploadService handles image uploads and serving the stored files
+type UploadService struct {
+	UploadRepo *repository.UploadRepository
+	Store      storage.BlobStore
+	Config     config.UploadConfig
+}
+
+// NewUploadService creates a new UploadService
+func NewUploadService(uploadRepo *repository.UploadRepository, store storage.BlobStore, cfg config.UploadConfig) *UploadService {
+	return &UploadService{
+		UploadRepo: uploadRepo,
+		Store:      store,
+		Config:     cfg,
+	}
+}
+
+// UploadImage stores an image sent as the "file" field of a multipart form.
+// The file type is detected from its content, metadata is stripped and a
+// thumbnail is generated. Uploading a file that is already stored returns
+// the existing upload.
+func UploadImage(UploadService *UploadService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		// Leave some room for the multipart headers around the file
+		r.Body = http.MaxBytesReader(w, r.Body, UploadService.Config.MaxBytes+64<<10)
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Missing file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, UploadService.Config.MaxBytes+1))
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(data)) > UploadService.Config.MaxBytes {
+			http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		image, err := utils.ProcessImage(data, UploadService.Config.MaxPixels, UploadService.Config.ThumbnailSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Identical files are stored once
+		upload, err := UploadService.UploadRepo.GetBySHA256(image.SHA256)
+		switch err {
+		case nil:
+			if err = UploadService.UploadRepo.AddUploader(user.ID, upload.ID); err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(upload)
+			return
+		case config.ErrUploadNotFound:
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Files are named after their hash, under a prefix per hash prefix
+		key := path.Join(image.SHA256[:2], image.SHA256+image.Extension)
+		thumbnailKey := path.Join(image.SHA256[:2], image.SHA256+"_thumb"+image.ThumbnailExt)
+
+		err = UploadService.Store.Put(key, image.Data, image.ContentType)
+		if err == nil {
+			err = UploadService.Store.Put(thumbnailKey, image.Thumbnail, image.ThumbnailType)
+		}
+		if err != nil {
+			log.Printf("Failed to store upload: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		upload, err = UploadService.UploadRepo.Create(user.ID, models.Upload{
+			SHA256:        image.SHA256,
+			ContentType:   image.ContentType,
+			Size:          int64(len(image.Data)),
+			Width:         image.Width,
+			Height:        image.Height,
+			Path:          key,
+			ThumbnailPath: thumbnailKey,
+			ThumbnailType: image.ThumbnailType,
+		})
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(upload)
+	}
+}
+
+// ServeUpload serves a stored image
+func ServeUpload(UploadService *UploadService) http.HandlerFunc {
+	return uploadFileHandler(UploadService, false)
+}
+
+// ServeThumbnail serves the thumbnail of a stored image
+func ServeThumbnail(UploadService *UploadService) http.HandlerFunc {
+	return uploadFileHandler(UploadService, true)
+}
+
+// uploadFileHandler builds a handler redirecting to a signed download URL
+// for an upload or its thumbnail. The redirect can be cached for as long as
+// the URL it points to stays the same.
+func uploadFileHandler(UploadService *UploadService, thumbnail bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET and HEAD requests
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		upload, err := UploadService.UploadRepo.GetByID(r.PathValue("id"))
+		if err != nil {
+			switch err {
+			case config.ErrUploadNotFound:
+				http.Error(w, "Upload not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		key := upload.Path
+		if thumbnail {
+			key = upload.ThumbnailPath
+		}
+
+		signedURL, reuseUntil, err := UploadService.Store.SignedURL(key)
+		if err != nil {
+			log.Printf("Failed to sign download URL for upload %s: %v", upload.ID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(time.Until(reuseUntil).Seconds())))
+		http.Redirect(w, r, signedURL, http.StatusFound)
+	}
+}
+
+// ServeBlob serves a file from local storage through a signed URL. Files
+// never change once stored, so they are cached until the URL expires.
+func ServeBlob(store *storage.LocalStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET and HEAD requests
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := r.PathValue("key")
+		query := r.URL.Query()
+		expiresAt, ok := store.Verify(key, query.Get("expires"), query.Get("signature"))
+		if !ok {
+			http.Error(w, "Link is invalid or has expired", http.StatusForbidden)
+			return
+		}
+
+		file, err := store.Open(key)
+		if err != nil {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+		w.Header().Set("ETag", `"`+key+`"`)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(time.Until(expiresAt).Seconds())))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+		http.ServeContent(w, r, "", info.ModTime(), file)
+	}
+}
+
+// checkAttachments validates the upload IDs attached to new content and
+// returns them without duplicates. It writes the error response and returns
+// false when they are not acceptable.
+func checkAttachments(w http.ResponseWriter, uploadRepo *repository.UploadRepository, userID string, uploadIDs []string) ([]string, bool) {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, uploadID := range uploadIDs {
+		if !seen[uploadID] {
+			seen[uploadID] = true
+			unique = append(unique, uploadID)
+		}
+	}
+
+	if len(unique) > config.MAX_ATTACHMENTS {
+		http.Error(w, "Too many attachments", http.StatusBadRequest)
+		return nil, false
+	}
+
+	err := uploadRepo.CheckOwned(userID, unique)
+	if err != nil {
+		switch err {
+		case config.ErrInvalidAttachment:
+			http.Error(w, "Attachments must be your own uploads", http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+
+	return unique, true
+}
+
+// loadPostAttachments fills in the attachments of each post
+func loadPostAttachments(uploadRepo *repository.UploadRepository, posts []models.Post) error {
+	postIDs := make([]string, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+
+	attachments, err := uploadRepo.ListForPosts(postIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		if uploads, ok := attachments[posts[i].ID]; ok && !posts[i].Deleted {
+			posts[i].Attachments = uploads
+		}
+	}
+	return nil
+}
+
+// loadCommentAttachments fills in the attachments of each comment
+func loadCommentAttachments(uploadRepo *repository.UploadRepository, comments []models.Comment) error {
+	commentIDs := make([]string, len(comments))
+	for i := range comments {
+		commentIDs[i] = comments[i].ID
+	}
+
+	attachments, err := uploadRepo.ListForComments(commentIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		if uploads, ok := attachments[comments[i].ID]; ok && !comments[i].Deleted {
+			comments[i].Attachments = uploads
+		}
+	}
+	return nil
+}
+
+// loadDraftAttachments fills in the attachments of each draft
+func loadDraftAttachments(uploadRepo *repository.UploadRepository, drafts []models.Draft) error {
+	draftIDs := make([]string, len(drafts))
+	for i := range drafts {
+		draftIDs[i] = drafts[i].ID
+	}
+
+	attachments, err := uploadRepo.ListForPosts(draftIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range drafts {
+		if uploads, ok := attachments[drafts[i].ID]; ok {
+			drafts[i].Attachments = uploads
+		}
+	}
+	return nil
+}
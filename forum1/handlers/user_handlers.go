@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/middleware"
+	"forum/models"
+	"forum/repository"
+	"forum/utils"
+)
+
+// UserService handles requests about the current user's account
+type UserService struct {
+	UserRepo     *repository.UserRepository
+	SessionRepo  *repository.SessionRepository
+	ProfileRepo  *repository.ProfileRepository
+	DomainRepo   *repository.EmailDomainRepository
+	SecurityRepo *repository.SecurityEventRepository
+}
+
+// NewUserService creates a new UserService
+func NewUserService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, profileRepo *repository.ProfileRepository, domainRepo *repository.EmailDomainRepository, securityRepo *repository.SecurityEventRepository) *UserService {
+	return &UserService{
+		UserRepo:     userRepo,
+		SessionRepo:  sessionRepo,
+		ProfileRepo:  profileRepo,
+		DomainRepo:   domainRepo,
+		SecurityRepo: securityRepo,
+	}
+}
+
+// MyProfile handles GET (view) and PUT (replace) on the current user's profile
+func MyProfile(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			profile, err := UserService.ProfileRepo.GetByUserID(user.ID)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(profile)
+
+		case http.MethodPut:
+			// Parse request body
+			var profile models.UserProfile
+			err := json.NewDecoder(r.Body).Decode(&profile)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			// validate profile
+			utils.NormalizeProfile(&profile)
+			err = utils.ValidateProfile(profile)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			profile.UserID = user.ID
+			saved, err := UserService.ProfileRepo.Upsert(profile)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(saved)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// PublicUserProfile returns another user's public profile and activity stats
+func PublicUserProfile(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		profile, err := UserService.ProfileRepo.GetPublicByUsername(r.PathValue("username"))
+		if err != nil {
+			switch err {
+			case config.ErrUserNotFound:
+				http.Error(w, "User not found", http.StatusNotFound)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+	}
+}
+
+// SuggestUsernames completes an @mention with the users whose username
+// starts with "prefix"
+func SuggestUsernames(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		// The @ typed before the name is optional
+		prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "@")
+		if err := utils.ValidateUsernamePrefix(prefix); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = config.DEFAULT_USERNAME_SUGGESTIONS
+		}
+		limit = min(limit, config.MAX_USERNAME_SUGGESTIONS)
+
+		suggestions, err := UserService.UserRepo.SuggestUsernames(user.ID, prefix, limit)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(suggestions)
+	}
+}
+
+// ChangeEmail changes the current user's email after confirming their password
+func ChangeEmail(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow PUT requests
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		// Parse request body
+		var change models.EmailChange
+		err := json.NewDecoder(r.Body).Decode(&change)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Normalize and validate email the same way registration does
+		change.Email = utils.NormalizeEmail(change.Email)
+		err = utils.ValidateEmail(change.Email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !checkEmailDomain(w, UserService.DomainRepo, change.Email) {
+			return
+		}
+
+		// Confirm the password
+		auth, err := UserService.UserRepo.GetAuthByUserID(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !utils.CheckPasswordHash(change.Password, auth.PasswordHash) {
+			http.Error(w, "Invalid password", http.StatusUnauthorized)
+			return
+		}
+
+		err = UserService.UserRepo.UpdateEmail(user.ID, change.Email)
+		if err != nil {
+			switch err {
+			case config.ErrEmailTaken:
+				http.Error(w, "Email is already taken", http.StatusConflict)
+			default:
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		recordSecurityEvent(UserService.SecurityRepo, r, user.ID, config.SECURITY_EVENT_EMAIL_CHANGED, fmt.Sprintf("Changed from %s to %s", user.Email, change.Email))
+
+		user.Email = change.Email
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// ChangePassword changes the current user's password after confirming the current one
+func ChangePassword(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow PUT requests
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		// Parse request body
+		var change models.PasswordChange
+		err := json.NewDecoder(r.Body).Decode(&change)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Confirm the current password
+		auth, err := UserService.UserRepo.GetAuthByUserID(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !utils.CheckPasswordHash(change.CurrentPassword, auth.PasswordHash) {
+			http.Error(w, "Invalid password", http.StatusUnauthorized)
+			return
+		}
+
+		// validate the new password against the policy
+		err = utils.ValidatePassword(change.NewPassword, user.Username, user.Email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		passwordHash, err := utils.HashPassword(change.NewPassword)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		err = UserService.UserRepo.UpdatePasswordHash(user.ID, passwordHash)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		recordSecurityEvent(UserService.SecurityRepo, r, user.ID, config.SECURITY_EVENT_PASSWORD_CHANGED, "")
+
+		// Sign out everywhere else, in case the old password was known to someone
+		keep := ""
+		if cookie, err := r.Cookie("session_id"); err == nil {
+			keep = cookie.Value
+		}
+		revoked, err := UserService.SessionRepo.DeleteOthers(user.ID, keep)
+		if err != nil {
+			log.Printf("Failed to revoke other sessions of user %s: %v", user.ID, err)
+		} else if revoked > 0 {
+			recordSecurityEvent(UserService.SecurityRepo, r, user.ID, config.SECURITY_EVENT_SESSIONS_REVOKED, fmt.Sprintf("Signed out of %d other session(s) after a password change", revoked))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CurrentUser handles GET (view) and DELETE (delete account) on /api/me
+func CurrentUser(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := middleware.GetCurrentUser(r)
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(user)
+		case http.MethodDelete:
+			deleteAccount(UserService, w, r, user)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// deleteAccount deletes the current user after confirming their password
+func deleteAccount(UserService *UserService, w http.ResponseWriter, r *http.Request, user *models.User) {
+	// Parse request body
+	var deletion models.AccountDeletion
+	err := json.NewDecoder(r.Body).Decode(&deletion)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if deletion.Mode != config.DELETION_MODE_ERASE && deletion.Mode != config.DELETION_MODE_ANONYMISE {
+		http.Error(w, "Mode must be either \"erase\" or \"anonymise\"", http.StatusBadRequest)
+		return
+	}
+
+	// Confirm the password before doing anything irreversible
+	auth, err := UserService.UserRepo.GetAuthByUserID(user.ID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !utils.CheckPasswordHash(deletion.Password, auth.PasswordHash) {
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+
+	// Delete the account (sessions are removed with it)
+	err = UserService.UserRepo.Delete(user.ID, deletion.Mode)
+	if err != nil {
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+
+	// Clear the cookie
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportUserData returns a JSON archive of everything stored about the current user
+func ExportUserData(UserService *UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only allow GET requests
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// The archive holds the user's private messages and sessions
+		if middleware.GetImpersonator(r) != nil {
+			http.Error(w, "The data export is not available while viewing as another user", http.StatusForbidden)
+			return
+		}
+
+		user := middleware.GetCurrentUser(r)
+
+		export, err := UserService.UserRepo.ExportData(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		profile, err := UserService.ProfileRepo.GetByUserID(user.ID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		export.ProfileDetails = *profile
+
+		// A negative limit returns the whole log
+		export.SecurityEvents, err = UserService.SecurityRepo.ListByUserID(user.ID, -1, 0)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Offer the archive as a file download
+		filename := fmt.Sprintf("forum-export-%s-%s.json", user.Username, time.Now().Format("20060102"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(export)
+	}
+}
@@ -1,24 +1,59 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"time"
 
+	"forum/config"
 	"forum/database"
+	"forum/events"
+	"forum/handlers"
+	"forum/repository"
 	"forum/routes"
+	"forum/storage"
 	"forum/utils"
 )
 
 func main() {
+	rebuildSearch := flag.Bool("rebuild-search-index", false, "rebuild the full-text search index from existing posts and comments, then exit")
+	flag.Parse()
+
 	// Load environment variables from .env file
 	err := utils.LoadEnv(".env")
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// Configure password hashing parameters
+	hashConfig := config.LoadHashConfig()
+	utils.ConfigurePasswordHashing(hashConfig)
+
+	// Configure the password policy and load the breached password list
+	passwordPolicy, err := config.LoadPasswordPolicy(hashConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.ConfigurePasswordPolicy(passwordPolicy)
+	if passwordPolicy.BreachedListPath != "" {
+		if err := utils.LoadBreachedPasswords(passwordPolicy.BreachedListPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Configure usernames nobody may register
+	utils.ConfigureReservedUsernames(config.LoadReservedUsernames())
+
+	// Configure the static email domain allow/deny lists
+	utils.ConfigureEmailDomains(config.LoadEmailDomainConfig())
+
+	// Configure outgoing email
+	utils.ConfigureMail(config.LoadMailConfig())
+
 	// Fetch SERVER_URL from environment variable
 	serverUrl := os.Getenv("SERVER_URL")
 	if serverUrl == "" {
@@ -47,10 +82,77 @@ func main() {
 	}
 	defer db.Close()
 
+	// Rebuild the search index and exit when asked to
+	if *rebuildSearch {
+		if err := database.RebuildSearchIndex(db); err != nil {
+			log.Fatalf("Failed to rebuild search index: %v", err)
+		}
+		fmt.Println("Search index rebuilt")
+		return
+	}
+
+	// Promote the accounts listed in ADMIN_EMAILS
+	userRepo := repository.NewUserRepository(db)
+	for _, email := range config.LoadAdminEmails() {
+		if err := userRepo.SetRoleByEmail(email, config.ROLE_ADMIN); err != nil {
+			log.Printf("Could not promote %s to admin: %v", email, err)
+		}
+	}
+
+	// Set up the storage backend for uploaded files
+	blobStore, err := storage.NewBlobStore(config.LoadStorageConfig())
+	if err != nil {
+		log.Fatalf("Failed to set up storage: %v", err)
+	}
+	uploadConfig, err := config.LoadUploadConfig()
+	if err != nil {
+		log.Fatalf("Invalid upload settings: %v", err)
+	}
+
+	// Hard delete trashed posts and comments once their retention period is over
+	go purgeTrash(repository.NewTrashRepository(db), config.LoadModerationConfig().TrashRetention)
+
+	// Create the hub that fans real-time events out to connected clients
+	eventConfig := config.LoadEventConfig()
+	eventHub := events.NewHub(eventConfig.BufferSize, eventConfig.ReplaySize)
+
+	// Publish scheduled drafts once their time has come
+	go publishScheduledPosts(repository.NewDraftRepository(db), repository.NewMentionRepository(db), repository.NewNotificationRepository(db), eventHub)
+
 	// Setup routes
-	handler := routes.SetupRoutes(db)
+	handler := routes.SetupRoutes(db, blobStore, uploadConfig, eventHub)
 
 	// Start the server and log any fatal errors
 	fmt.Printf("Server is running on %s\n", host)
 	log.Fatal(http.ListenAndServe(host, handler))
 }
+
+// purgeTrash periodically hard deletes expired trash
+func purgeTrash(trashRepo *repository.TrashRepository, retention time.Duration) {
+	for {
+		purged, err := trashRepo.Purge(retention)
+		if err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted posts and comments", purged)
+		}
+		time.Sleep(config.TRASH_PURGE_INTERVAL)
+	}
+}
+
+// publishScheduledPosts periodically publishes drafts that are due and
+// notifies the users they mention
+func publishScheduledPosts(draftRepo *repository.DraftRepository, mentionRepo *repository.MentionRepository, notificationRepo *repository.NotificationRepository, hub *events.Hub) {
+	for {
+		published, err := draftRepo.PublishScheduled()
+		if err != nil {
+			log.Printf("Failed to publish scheduled posts: %v", err)
+		} else if len(published) > 0 {
+			log.Printf("Published %d scheduled posts", len(published))
+		}
+		for _, postID := range published {
+			handlers.NotifyPublished(mentionRepo, notificationRepo, hub, postID)
+		}
+		time.Sleep(config.SCHEDULED_PUBLISH_INTERVAL)
+	}
+}
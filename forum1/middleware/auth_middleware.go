@@ -2,24 +2,41 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 
+	"forum/config"
 	"forum/models"
 	"forum/repository"
 )
 
 // Authentication middleware checks if the user is authenticated
 type AuthMiddleware struct {
-	SessionRepo *repository.SessionRepository
-	UserRepo    *repository.UserRepository
+	SessionRepo   *repository.SessionRepository
+	UserRepo      *repository.UserRepository
+	SanctionRepo  *repository.SanctionRepository
+	SecurityRepo  *repository.SecurityEventRepository
+	Impersonation config.ImpersonationConfig
+
+	// impersonationRoutes matches the routes usable while impersonating
+	impersonationRoutes *http.ServeMux
 }
 
 // NewAuthMiddleware creates a new AuthMiddleware
-func NewAuthMiddleware(sessionRepo *repository.SessionRepository, userRepo *repository.UserRepository) *AuthMiddleware {
+func NewAuthMiddleware(sessionRepo *repository.SessionRepository, userRepo *repository.UserRepository, sanctionRepo *repository.SanctionRepository, securityRepo *repository.SecurityEventRepository, impersonation config.ImpersonationConfig) *AuthMiddleware {
+	impersonationRoutes := http.NewServeMux()
+	for _, pattern := range config.IMPERSONATION_ROUTES {
+		impersonationRoutes.Handle(pattern, http.NotFoundHandler())
+	}
+
 	return &AuthMiddleware{
-		SessionRepo: sessionRepo,
-		UserRepo:    userRepo,
+		SessionRepo:         sessionRepo,
+		UserRepo:            userRepo,
+		SanctionRepo:        sanctionRepo,
+		SecurityRepo:        securityRepo,
+		Impersonation:       impersonation,
+		impersonationRoutes: impersonationRoutes,
 	}
 }
 
@@ -60,12 +77,91 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		log.Printf("User retrieved successfully: %+v", user)
 
+		// Only active accounts are authenticated
+		if user.Status != config.USER_STATUS_ACTIVE {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Suspended or banned users lose their session immediately
+		restriction, err := m.SanctionRepo.GetActiveRestriction(user.ID)
+		if err != nil {
+			log.Printf("Failed to check sanctions: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if restriction != nil {
+			log.Printf("Revoking session of restricted user %s (%s)", user.ID, restriction.SanctionType)
+			revoked, err := m.SessionRepo.DeleteByUserID(user.ID)
+			if err != nil {
+				log.Printf("Failed to revoke sessions of user %s: %v", user.ID, err)
+			} else if revoked > 0 {
+				details := fmt.Sprintf("Signed out while %s", restriction.SanctionType)
+				if err := m.SecurityRepo.Record(user.ID, config.SECURITY_EVENT_SESSIONS_REVOKED, "", "", details); err != nil {
+					log.Printf("Failed to record session revocation of user %s: %v", user.ID, err)
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Admins viewing the forum as another user act as that user
+		if target := m.impersonatedUser(r, user); target != nil {
+			w.Header().Set("X-Impersonated-By", user.Username)
+
+			// Only the listed routes are available, so nothing that would let
+			// the admin act as the user outside of this session leaks
+			if _, pattern := m.impersonationRoutes.Handler(r); pattern == "" {
+				http.Error(w, "This is not available while viewing as another user", http.StatusForbidden)
+				return
+			}
+
+			// Write actions are refused unless explicitly allowed; ending the
+			// impersonation is always possible
+			if !m.Impersonation.AllowWrites && !isReadOnly(r) && r.URL.Path != "/api/impersonation" {
+				http.Error(w, "Write actions are disabled while viewing as another user", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "user", target)
+			ctx = context.WithValue(ctx, "impersonator", user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		// Set user in context
 		ctx := context.WithValue(r.Context(), "user", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// impersonatedUser returns the user an admin is currently viewing the forum
+// as, or nil when the request carries no valid impersonation session
+func (m *AuthMiddleware) impersonatedUser(r *http.Request, admin *models.User) *models.User {
+	cookie, err := r.Cookie(config.IMPERSONATION_COOKIE)
+	if err != nil || admin.Role != config.ROLE_ADMIN {
+		return nil
+	}
+
+	impersonation, err := m.SessionRepo.GetImpersonation(cookie.Value)
+	if err != nil || impersonation.AdminID != admin.ID {
+		return nil
+	}
+
+	target, err := m.UserRepo.GetByID(impersonation.TargetUserID)
+	if err != nil {
+		log.Printf("Failed to get impersonated user: %v", err)
+		return nil
+	}
+
+	return target
+}
+
+// isReadOnly reports whether a request method cannot change anything
+func isReadOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+}
+
 // RequireAuth middleware ensures the user is authenticated
 func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -78,6 +174,24 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// RequireRole middleware ensures the user is authenticated and has one of the given roles
+func (m *AuthMiddleware) RequireRole(next http.Handler, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetCurrentUser(r)
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		for _, role := range roles {
+			if user.Role == role {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
 // GetCurrentUser returns the authenticated user from the context
 func GetCurrentUser(r *http.Request) *models.User {
 
@@ -94,3 +208,12 @@ func GetCurrentUser(r *http.Request) *models.User {
 
 	return user
 }
+
+// GetImpersonator returns the admin behind an impersonated request, or nil
+func GetImpersonator(r *http.Request) *models.User {
+	admin, ok := r.Context().Value("impersonator").(*models.User)
+	if !ok {
+		return nil
+	}
+	return admin
+}
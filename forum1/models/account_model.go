@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// UserDataExport is the archive returned by the personal data export
+type UserDataExport struct {
+	ExportedAt     time.Time         `json:"exported_at"`
+	Profile        User              `json:"profile"`
+	ProfileDetails UserProfile       `json:"profile_details"`
+	Posts          []Post            `json:"posts"`
+	Drafts         []Draft           `json:"drafts"`
+	Comments       []Comment         `json:"comments"`
+	Reactions      []Reaction        `json:"reactions"`
+	Messages       []Message         `json:"messages"`
+	Bookmarks      []Bookmark        `json:"bookmarks"`
+	Sessions       []ExportedSession `json:"sessions"`
+	SecurityEvents []SecurityEvent   `json:"security_events"`
+}
+
+// ExportedSession describes a session in the data export. The session ID is
+// left out, as it would let anyone holding the archive sign in as the user.
+type ExportedSession struct {
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AccountDeletion is used for account deletion requests
+type AccountDeletion struct {
+	Password string `json:"password" binding:"required"`
+	Mode     string `json:"mode" binding:"required"` // "erase" or "anonymise"
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Bookmark is a post or comment saved by a user, with an optional folder and
+// a note only they can see
+type Bookmark struct {
+	ID        string     `json:"id"`
+	PostID    *string    `json:"post_id,omitempty"`
+	CommentID *string    `json:"comment_id,omitempty"`
+	Folder    string     `json:"folder,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Post      *Post      `json:"post,omitempty"`    // the bookmarked post, when listing
+	Comment   *Comment   `json:"comment,omitempty"` // the bookmarked comment, when listing
+}
+
+// BookmarkCreation is used to bookmark a post or comment, or to change the
+// folder and note of an existing bookmark
+type BookmarkCreation struct {
+	Folder string `json:"folder"`
+	Note   string `json:"note"`
+}
+
+// BookmarkFolder is a folder of a user's bookmarks
+type BookmarkFolder struct {
+	Name      string `json:"name"`
+	Bookmarks int    `json:"bookmarks"`
+}
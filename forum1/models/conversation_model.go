@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Conversation is a private one-to-one or group conversation, as seen by one of its members
+type Conversation struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title,omitempty"`
+	Group         bool                 `json:"group"`
+	Members       []ConversationMember `json:"members"`
+	CreatedAt     time.Time            `json:"created_at"`
+	LastMessageAt *time.Time           `json:"last_message_at,omitempty"`
+	Unread        int                  `json:"unread"`
+	Muted         bool                 `json:"muted"`
+}
+
+// ConversationMember is a member of a conversation and how far they have read
+type ConversationMember struct {
+	UserID     string     `json:"user_id"`
+	Username   string     `json:"username"`
+	LastReadAt *time.Time `json:"last_read_at,omitempty"`
+}
+
+// ConversationCreation is used to start a conversation with one or more
+// users, optionally with a first message
+type ConversationCreation struct {
+	Usernames []string `json:"usernames"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+}
+
+// Message is a message in a conversation. ReadBy lists the other members who have read it.
+type Message struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	SenderID       string    `json:"sender_id"`
+	Sender         string    `json:"sender"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+	ReadBy         []string  `json:"read_by"`
+}
+
+// MessageCreation is used to send a message
+type MessageCreation struct {
+	Content string `json:"content"`
+}
+
+// MessagesRead tells the members of a conversation how far one of them has read
+type MessagesRead struct {
+	ConversationID string    `json:"conversation_id"`
+	UserID         string    `json:"user_id"`
+	Username       string    `json:"username"`
+	LastReadAt     time.Time `json:"last_read_at"`
+}
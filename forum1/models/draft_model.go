@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Draft is an unpublished post, visible only to its author
+type Draft struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	CategoryID  string     `json:"category_id"`
+	Content     string     `json:"content"`                // Markdown source
+	ContentHTML string     `json:"content_html,omitempty"` // sanitised rendering of Content
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"` // last save
+	PublishAt   *time.Time `json:"publish_at"`           // scheduled publication, null when unscheduled
+	Attachments []Upload   `json:"attachments"`
+}
+
+// DraftUpdate is used to create and autosave drafts. Omitted fields are left
+// unchanged, so clients can send only what changed.
+type DraftUpdate struct {
+	CategoryID  *string   `json:"category_id"`
+	Content     *string   `json:"content"`
+	Attachments *[]string `json:"attachments"` // upload IDs, replacing the current ones
+}
+
+// DraftSchedule is used to publish a draft, now or at a later time
+type DraftSchedule struct {
+	PublishAt *time.Time `json:"publish_at"` // omitted or null to publish immediately
+}
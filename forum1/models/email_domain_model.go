@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// EmailDomainRule allows or denies registrations from an email domain
+type EmailDomainRule struct {
+	Domain    string    `json:"domain"`
+	RuleType  string    `json:"rule_type"` // allow or deny
+	CreatedBy *string   `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EmailDomainRules lists the rules in effect, split by where they come from
+type EmailDomainRules struct {
+	Rules           []EmailDomainRule `json:"rules"`
+	StaticAllowlist []string          `json:"static_allowlist"`
+	StaticDenylist  []string          `json:"static_denylist"`
+	BlockDisposable bool              `json:"block_disposable"`
+}
+
+// EmailChange is used to change the current user's email
+type EmailChange struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ImpersonationSession is an admin's audited "view as user" session
+type ImpersonationSession struct {
+	ID             string     `json:"id"`
+	SessionID      string     `json:"-"`
+	AdminID        string     `json:"admin_id"`
+	AdminUsername  string     `json:"admin_username"`
+	TargetUserID   string     `json:"target_user_id"`
+	TargetUsername string     `json:"target_username"`
+	Reason         string     `json:"reason"`
+	IPAddress      string     `json:"ip_address"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+}
+
+// ImpersonationRequest is used to start impersonating a user
+type ImpersonationRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ImpersonationStatus describes the impersonation the current request runs under
+type ImpersonationStatus struct {
+	Impersonation ImpersonationSession `json:"impersonation"`
+	Admin         User                 `json:"admin"`
+	ViewingAs     User                 `json:"viewing_as"`
+	WritesAllowed bool                 `json:"writes_allowed"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Invite is a registration code with a usage limit and an expiry
+type Invite struct {
+	Code      string     `json:"code"`
+	CreatedBy string     `json:"created_by"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// InviteCreation is used to create a new invite code
+type InviteCreation struct {
+	MaxUses   int    `json:"max_uses"`
+	ExpiresIn string `json:"expires_in"` // Go duration such as "168h"
+}
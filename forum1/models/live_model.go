@@ -0,0 +1,20 @@
+package models
+
+import "encoding/json"
+
+// LiveMessage is a message on a live thread connection, in either direction
+type LiveMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Viewer is a user who has a thread's live view open
+type Viewer struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// ThreadPresence lists who is viewing a thread
+type ThreadPresence struct {
+	Viewers []Viewer `json:"viewers"`
+}
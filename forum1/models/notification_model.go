@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Notification tells a user about activity on their content or a moderator action
+type Notification struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"-"` // recipient
+	Type         string     `json:"type"`
+	ActorID      *string    `json:"actor_id,omitempty"` // hidden for moderator actions, nil for deleted users
+	Actor        *string    `json:"actor,omitempty"`
+	PostID       *string    `json:"post_id,omitempty"`
+	CommentID    *string    `json:"comment_id,omitempty"`
+	ReactionType int        `json:"reaction_type,omitempty"`
+	Message      string     `json:"message,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReadAt       *time.Time `json:"read_at,omitempty"`
+}
+
+// UnreadCount is the number of unread notifications or messages of a user
+type UnreadCount struct {
+	Unread int `json:"unread"`
+}
+
+// NotificationPreferences maps each notification type to whether it is delivered
+type NotificationPreferences map[string]bool
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Post represents a forum post
+type Post struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	Author       string     `json:"author,omitempty"`
+	CategoryID   string     `json:"category_id"`
+	Content      string     `json:"content"`                // Markdown source
+	ContentHTML  string     `json:"content_html,omitempty"` // sanitised rendering of Content
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	Likes        int        `json:"likes"`
+	Dislikes     int        `json:"dislikes"`
+	CommentCount int        `json:"comment_count"`
+	Attachments  []Upload   `json:"attachments"`
+	Deleted      bool       `json:"deleted,omitempty"`    // content replaced by a placeholder
+	Bookmarked   *bool      `json:"bookmarked,omitempty"` // by the current user, when signed in
+}
+
+// Comment represents a comment on a post
+type Comment struct {
+	ID          string     `json:"id"`
+	PostID      string     `json:"post_id"`
+	ReplyTo     *string    `json:"reply_to,omitempty"` // comment this one answers
+	UserID      string     `json:"user_id"`
+	Author      string     `json:"author,omitempty"`
+	Content     string     `json:"content"`                // Markdown source
+	ContentHTML string     `json:"content_html,omitempty"` // sanitised rendering of Content
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	Likes       int        `json:"likes"`
+	Dislikes    int        `json:"dislikes"`
+	Attachments []Upload   `json:"attachments"`
+	Deleted     bool       `json:"deleted,omitempty"`    // content replaced by a placeholder
+	Bookmarked  *bool      `json:"bookmarked,omitempty"` // by the current user, when signed in
+}
+
+// PostCreation is used for new post requests
+type PostCreation struct {
+	CategoryID  string   `json:"category_id" binding:"required"`
+	Content     string   `json:"content" binding:"required"`
+	Attachments []string `json:"attachments"` // upload IDs
+}
+
+// CommentCreation is used for new comment requests
+type CommentCreation struct {
+	Content     string   `json:"content" binding:"required"`
+	ReplyTo     string   `json:"reply_to"`    // optional comment of the same post
+	Attachments []string `json:"attachments"` // upload IDs
+}
+
+// Thread is a post together with its visible comments
+type Thread struct {
+	Post     Post      `json:"post"`
+	Comments []Comment `json:"comments"`
+}
+
+// MarkdownPreview is used to render content without posting it
+type MarkdownPreview struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// RenderedContent is the sanitised HTML of a Markdown preview
+type RenderedContent struct {
+	HTML string `json:"html"`
+}
+
+// Category represents a post category
+type Category struct {
+	ID     string `json:"id"`
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// UserProfile contains the editable, public parts of a user's profile
+type UserProfile struct {
+	UserID      string     `json:"-"`
+	DisplayName string     `json:"display_name"`
+	Bio         string     `json:"bio"`
+	Location    string     `json:"location"`
+	Website     string     `json:"website"`
+	AvatarURL   string     `json:"avatar_url"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// ProfileStats are activity counters computed for a profile
+type ProfileStats struct {
+	PostCount         int `json:"post_count"`
+	CommentCount      int `json:"comment_count"`
+	LikesReceived     int `json:"likes_received"`
+	DislikesReceived  int `json:"dislikes_received"`
+	ReactionsReceived int `json:"reactions_received"`
+}
+
+// UsernameSuggestion is a user offered when completing an @mention
+type UsernameSuggestion struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// PublicProfile is what other users can see about a user. It never contains the email.
+type PublicProfile struct {
+	Username string       `json:"username"`
+	Role     string       `json:"role"`
+	JoinedAt time.Time    `json:"joined_at"`
+	Profile  UserProfile  `json:"profile"`
+	Stats    ProfileStats `json:"stats"`
+}
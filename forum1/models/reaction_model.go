@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Reaction represents a like or dislike on a post or comment
+type Reaction struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	ReactionType int       `json:"reaction_type"` // 1 for like, 2 for dislike
+	PostID       *string   `json:"post_id,omitempty"`
+	CommentID    *string   `json:"comment_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ReactionRequest is used to like or dislike a post or comment
+type ReactionRequest struct {
+	ReactionType int `json:"reaction_type" binding:"required"`
+}
+
+// ReactionCounts are the totals returned after reacting
+type ReactionCounts struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+	Mine     int `json:"mine"` // the current user's reaction, 0 if none
+}
+
+// ReactionUpdate is streamed to the viewers of a thread when the totals of
+// the post or one of its comments change
+type ReactionUpdate struct {
+	PostID    string  `json:"post_id"`
+	CommentID *string `json:"comment_id,omitempty"`
+	Likes     int     `json:"likes"`
+	Dislikes  int     `json:"dislikes"`
+}
@@ -4,7 +4,14 @@ import ()
 
 // UserRegistration is used for registration requests
 type UserRegistration struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
-}
\ No newline at end of file
+	Username   string `json:"username" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required,min=8"`
+	InviteCode string `json:"invite_code"` // required when registration is invite-only
+}
+
+// RegistrationAccepted is returned for every valid registration when uniform
+// responses are enabled, whether or not the email was already in use
+type RegistrationAccepted struct {
+	Message string `json:"message"`
+}
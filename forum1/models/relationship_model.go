@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// UserRelation is a block or mute of another user
+type UserRelation struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
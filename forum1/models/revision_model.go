@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Revision is one version of a post or comment. Revision 1 is the content
+// as originally posted.
+type Revision struct {
+	Number    int        `json:"number"`
+	Content   string     `json:"content"`
+	EditorID  string     `json:"editor_id"`
+	Editor    string     `json:"editor"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Diff      []DiffLine `json:"diff,omitempty"` // changes from the previous revision
+}
+
+// DiffLine is a line of a diff between two revisions
+type DiffLine struct {
+	Op   string `json:"op"` // "equal", "insert" or "delete"
+	Text string `json:"text"`
+}
+
+// RevisionDiff compares two chosen revisions
+type RevisionDiff struct {
+	From int        `json:"from"`
+	To   int        `json:"to"`
+	Diff []DiffLine `json:"diff"`
+}
+
+// ContentEdit is used to edit a post or comment
+type ContentEdit struct {
+	Content string `json:"content" binding:"required"`
+	Reason  string `json:"reason"`
+}
+
+// RevisionRestore is used by moderators to restore an earlier revision
+type RevisionRestore struct {
+	Reason string `json:"reason"`
+}
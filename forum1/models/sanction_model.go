@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Sanction is a warning, suspension or ban issued against a user
+type Sanction struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	ModeratorID  string     `json:"moderator_id"`
+	SanctionType string     `json:"sanction_type"` // warning, suspension or ban
+	Reason       string     `json:"reason"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // only set for suspensions
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    *string    `json:"revoked_by,omitempty"`
+}
+
+// SanctionRequest is used by moderators to issue a sanction
+type SanctionRequest struct {
+	SanctionType string `json:"sanction_type" binding:"required"`
+	Reason       string `json:"reason" binding:"required"`
+	Duration     string `json:"duration"` // Go duration such as "72h", required for suspensions
+}
+
+// SanctionHistory lists every sanction of a user together with their current state
+type SanctionHistory struct {
+	User           User       `json:"user"`
+	ActiveWarnings int        `json:"active_warnings"`
+	Restriction    *Sanction  `json:"restriction,omitempty"`
+	Sanctions      []Sanction `json:"sanctions"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// SearchQuery holds the parsed parameters of a search request
+type SearchQuery struct {
+	Match      string     // FTS5 query built from the user's terms
+	Type       string     // "post", "comment" or "" for both
+	CategoryID string     // "" for every category
+	Author     string     // username, "" for every author
+	From       *time.Time // inclusive
+	To         *time.Time // exclusive
+	Limit      int
+	Offset     int
+}
+
+// SearchResult is a post or comment matching a search
+type SearchResult struct {
+	Type       string    `json:"type"`
+	PostID     string    `json:"post_id"`
+	CommentID  string    `json:"comment_id,omitempty"`
+	AuthorID   string    `json:"author_id"`
+	Author     string    `json:"author"`
+	CategoryID string    `json:"category_id"`
+	Snippet    string    `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+	Rank       float64   `json:"rank"`    // lower is more relevant
+	CreatedAt  time.Time `json:"created_at"`
+}
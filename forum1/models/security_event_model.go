@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SecurityEvent is an entry in a user's security log
+type SecurityEvent struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	EventType string    `json:"event_type"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PasswordChange is used for password change requests
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
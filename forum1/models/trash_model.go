@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// TrashItem is a deleted post or comment waiting to be hard deleted
+type TrashItem struct {
+	Type        string    `json:"type"` // "post" or "comment"
+	ID          string    `json:"id"`
+	PostID      string    `json:"post_id"`
+	AuthorID    string    `json:"author_id"`
+	Author      string    `json:"author"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	DeletedByID *string   `json:"deleted_by_id"`
+	DeletedBy   *string   `json:"deleted_by"`
+	Reason      string    `json:"reason,omitempty"`
+	PurgeAt     time.Time `json:"purge_at"`
+}
+
+// ContentDeletion is used to delete a post or comment
+type ContentDeletion struct {
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Upload is a stored image. Identical files are stored once and shared.
+type Upload struct {
+	ID            string    `json:"id"`
+	SHA256        string    `json:"sha256"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	Width         int       `json:"width"`
+	Height        int       `json:"height"`
+	URL           string    `json:"url"`
+	ThumbnailURL  string    `json:"thumbnail_url"`
+	Path          string    `json:"-"` // storage key
+	ThumbnailPath string    `json:"-"`
+	ThumbnailType string    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -7,6 +7,8 @@ type User struct {
 	ID        string    `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -17,6 +19,11 @@ type UserAuth struct {
 	PasswordHash string `json:"-"`
 }
 
+// RoleChange is used by admins to change a user's role
+type RoleChange struct {
+	Role string `json:"role" binding:"required"` // "user" or "moderator"
+}
+
 // UserLogin is used for login requests
 type UserLogin struct {
 	Email    string `json:"email" binding:"required,email"`
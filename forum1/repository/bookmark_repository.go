@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// bookmarkSelect selects a bookmark without its post or comment
+const bookmarkSelect = `SELECT bookmark_id, post_id, comment_id, folder, note, created_at, updated_at FROM bookmarks`
+
+// BookmarkRepository handles the posts and comments users save for later
+type BookmarkRepository struct {
+	DB *sql.DB
+}
+
+// NewBookmarkRepository creates a new BookmarkRepository
+func NewBookmarkRepository(db *sql.DB) *BookmarkRepository {
+	return &BookmarkRepository{DB: db}
+}
+
+// Save bookmarks the post or comment in targetColumn for a user, or changes
+// the folder and note of their existing bookmark. Only content that is
+// published and not deleted can be bookmarked. It reports whether the
+// bookmark is new.
+func (r *BookmarkRepository) Save(userID, targetColumn, targetID, folder, note string) (*models.Bookmark, bool, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(
+		fmt.Sprintf("UPDATE bookmarks SET folder = ?, note = ?, updated_at = ? WHERE user_id = ? AND %s = ?", targetColumn),
+		folder, note, now, userID, targetID,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	created := rows == 0
+	if created {
+		// Check the target can be bookmarked
+		var count int
+		errNotFound := config.ErrPostNotFound
+		if targetColumn == "post_id" {
+			err = tx.QueryRow("SELECT COUNT(*) FROM posts WHERE post_id = ? AND draft = 0 AND deleted_at IS NULL", targetID).Scan(&count)
+		} else {
+			errNotFound = config.ErrCommentNotFound
+			err = tx.QueryRow("SELECT COUNT(*) FROM comments WHERE comment_id = ? AND deleted_at IS NULL", targetID).Scan(&count)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if count == 0 {
+			return nil, false, errNotFound
+		}
+
+		_, err = tx.Exec(
+			fmt.Sprintf("INSERT INTO bookmarks (bookmark_id, user_id, %s, folder, note, created_at) VALUES (?, ?, ?, ?, ?, ?)", targetColumn),
+			utils.GenerateUUID(), userID, targetID, folder, note, now,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	bookmark, err := scanBookmark(tx.QueryRow(
+		fmt.Sprintf(bookmarkSelect+" WHERE user_id = ? AND %s = ?", targetColumn),
+		userID, targetID,
+	))
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	return bookmark, created, nil
+}
+
+// Delete removes a user's bookmark of the post or comment in targetColumn
+func (r *BookmarkRepository) Delete(userID, targetColumn, targetID string) error {
+	result, err := r.DB.Exec(fmt.Sprintf("DELETE FROM bookmarks WHERE user_id = ? AND %s = ?", targetColumn), userID, targetID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrBookmarkNotFound
+	}
+
+	return nil
+}
+
+// List returns a page of a user's bookmarks, newest first, each with the post
+// or comment it saves. A nil folder lists every folder; "" lists the
+// bookmarks not filed in one. Bookmarked content deleted since is returned as
+// a placeholder.
+func (r *BookmarkRepository) List(userID string, folder *string, limit, offset int) ([]models.Bookmark, error) {
+	query := bookmarkSelect + " WHERE user_id = ?"
+	args := []any{userID}
+	if folder != nil {
+		query += " AND folder = ?"
+		args = append(args, *folder)
+	}
+	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []models.Bookmark{}
+	for rows.Next() {
+		bookmark, err := scanBookmark(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, *bookmark)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load what each bookmark saves
+	for i := range bookmarks {
+		if bookmarks[i].PostID != nil {
+			bookmarks[i].Post, err = scanPost(r.DB.QueryRow(postSelect+" WHERE p.post_id = ?", *bookmarks[i].PostID))
+		} else {
+			bookmarks[i].Comment, err = scanComment(r.DB.QueryRow(commentSelect+" WHERE c.comment_id = ?", *bookmarks[i].CommentID))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bookmarks, nil
+}
+
+// ListFolders returns the folders a user has filed bookmarks in, by name,
+// with how many bookmarks each holds
+func (r *BookmarkRepository) ListFolders(userID string) ([]models.BookmarkFolder, error) {
+	rows, err := r.DB.Query(
+		`SELECT folder, COUNT(*) FROM bookmarks
+		WHERE user_id = ? AND folder != ''
+		GROUP BY folder
+		ORDER BY folder COLLATE NOCASE`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []models.BookmarkFolder{}
+	for rows.Next() {
+		var folder models.BookmarkFolder
+		if err := rows.Scan(&folder.Name, &folder.Bookmarks); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, rows.Err()
+}
+
+// ListBookmarked returns which of the posts or comments in targetColumn a
+// user has bookmarked
+func (r *BookmarkRepository) ListBookmarked(userID, targetColumn string, targetIDs []string) (map[string]bool, error) {
+	bookmarked := map[string]bool{}
+	if len(targetIDs) == 0 {
+		return bookmarked, nil
+	}
+
+	args := make([]any, 0, len(targetIDs)+1)
+	args = append(args, userID)
+	for _, id := range targetIDs {
+		args = append(args, id)
+	}
+
+	rows, err := r.DB.Query(
+		`SELECT `+targetColumn+` FROM bookmarks
+		WHERE user_id = ? AND `+targetColumn+` IN (?`+strings.Repeat(", ?", len(targetIDs)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID string
+		if err := rows.Scan(&targetID); err != nil {
+			return nil, err
+		}
+		bookmarked[targetID] = true
+	}
+
+	return bookmarked, rows.Err()
+}
+
+// scanBookmark scans a row produced by bookmarkSelect
+func scanBookmark(row scanner) (*models.Bookmark, error) {
+	var bookmark models.Bookmark
+	err := row.Scan(&bookmark.ID, &bookmark.PostID, &bookmark.CommentID, &bookmark.Folder, &bookmark.Note, &bookmark.CreatedAt, &bookmark.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &bookmark, nil
+}
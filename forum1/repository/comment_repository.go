@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// commentSelect selects a comment with its author, reaction counts and
+// mentioned usernames
+const commentSelect = `SELECT c.comment_id, c.post_id, c.user_id, u.username, c.content, c.created_at, c.updated_at, c.deleted_at, c.reply_to,
+	(SELECT COUNT(*) FROM reactions WHERE comment_id = c.comment_id AND reaction_type = 1),
+	(SELECT COUNT(*) FROM reactions WHERE comment_id = c.comment_id AND reaction_type = 2),
+	(SELECT group_concat(mu.username, ' ') FROM mentions m JOIN user mu ON mu.user_id = m.user_id WHERE m.comment_id = c.comment_id)
+FROM comments c JOIN user u ON u.user_id = c.user_id`
+
+// CommentRepository handles comment-related database operations
+type CommentRepository struct {
+	DB *sql.DB
+}
+
+// NewCommentRepository creates a new CommentRepository
+func NewCommentRepository(db *sql.DB) *CommentRepository {
+	return &CommentRepository{DB: db}
+}
+
+// Create adds a comment to a post, optionally as a reply to another comment
+// of the same post (replyTo is "" otherwise). The insert only happens when
+// the post is published, is not deleted and neither its author nor the
+// author of the comment replied to has blocked the commenter.
+func (r *CommentRepository) Create(postID, userID, content, replyTo string, attachments []string) (*models.Comment, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	commentID := utils.GenerateUUID()
+	createdAt := time.Now()
+
+	result, err := tx.Exec(
+		`INSERT INTO comments (comment_id, post_id, user_id, content, created_at, reply_to)
+		SELECT ?, p.post_id, ?, ?, ?, NULLIF(?, '') FROM posts p
+		WHERE p.post_id = ? AND p.deleted_at IS NULL AND p.draft = 0
+			AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = p.user_id AND b.blocked_id = ?)
+			AND (? = '' OR EXISTS (
+				SELECT 1 FROM comments rc WHERE rc.comment_id = ? AND rc.post_id = p.post_id AND rc.deleted_at IS NULL
+					AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = rc.user_id AND b.blocked_id = ?)))`,
+		commentID, userID, content, createdAt, replyTo, postID, userID, replyTo, replyTo, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, explainRejectedReply(tx, postID, replyTo)
+	}
+
+	if err = insertAttachments(tx, "comment_id", commentID, attachments); err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(commentID)
+}
+
+// explainRejectedReply tells apart a missing post or reply target from a
+// blocked commenter
+func explainRejectedReply(db querier, postID, replyTo string) error {
+	var postExists, targetExists bool
+	err := db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM posts WHERE post_id = ? AND deleted_at IS NULL AND draft = 0),
+			? = '' OR EXISTS (SELECT 1 FROM comments WHERE comment_id = ? AND post_id = ? AND deleted_at IS NULL)`,
+		postID, replyTo, replyTo, postID,
+	).Scan(&postExists, &targetExists)
+	if err != nil {
+		return err
+	}
+	if !postExists {
+		return config.ErrPostNotFound
+	}
+	if !targetExists {
+		return config.ErrReplyTargetNotFound
+	}
+	return config.ErrBlocked
+}
+
+// GetByID retrieves a comment by its ID. Deleted comments are returned as placeholders.
+func (r *CommentRepository) GetByID(commentID string) (*models.Comment, error) {
+	comment, err := scanComment(r.DB.QueryRow(commentSelect+" WHERE c.comment_id = ?", commentID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrCommentNotFound
+		}
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// ListByPost returns the comments of a post, oldest first, leaving out
+// comments by authors the viewer has muted or blocked. Deleted comments stay
+// in place as placeholders so the conversation still makes sense.
+func (r *CommentRepository) ListByPost(postID, viewerID string) ([]models.Comment, error) {
+	rows, err := r.DB.Query(
+		commentSelect+`
+		WHERE c.post_id = ? AND c.user_id `+hiddenAuthorsClause+`
+		ORDER BY c.created_at`,
+		postID, viewerID, viewerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// scanComment scans a row produced by commentSelect, replacing the content
+// of a deleted comment with a placeholder
+func scanComment(row scanner) (*models.Comment, error) {
+	var comment models.Comment
+	var deletedAt *time.Time
+	var mentioned *string
+	err := row.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Author, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt, &deletedAt,
+		&comment.ReplyTo, &comment.Likes, &comment.Dislikes, &mentioned)
+	if err != nil {
+		return nil, err
+	}
+	comment.Attachments = []models.Upload{}
+	if deletedAt != nil {
+		comment.Deleted = true
+		comment.Content = config.DELETED_CONTENT_PLACEHOLDER
+		return &comment, nil
+	}
+	comment.ContentHTML = utils.RenderMarkdown(comment.Content, splitMentioned(mentioned))
+	return &comment, nil
+}
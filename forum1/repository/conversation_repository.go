@@ -0,0 +1,423 @@
+package repository
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// unreadClause matches the messages of conversation member "me" that are
+// unread: sent by someone else after me.last_read_at and not by a user me
+// muted or blocked. It takes the member's user ID twice.
+const unreadClause = `m.sender_id != me.user_id AND (me.last_read_at IS NULL OR m.created_at > me.last_read_at)
+	AND m.sender_id ` + hiddenAuthorsClause
+
+// conversationSelect selects the conversations of a member with their unread
+// count. It takes the member's user ID three times.
+const conversationSelect = `SELECT c.conversation_id, c.title, c.direct_key IS NULL, c.created_at, c.last_message_at, me.muted,
+	(SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.conversation_id AND ` + unreadClause + `)
+FROM conversations c JOIN conversation_members me ON me.conversation_id = c.conversation_id AND me.user_id = ?`
+
+// messageSelect selects a message with its sender and the other members who read it
+const messageSelect = `SELECT m.message_id, m.conversation_id, m.sender_id, u.username, m.content, m.created_at,
+	(SELECT group_concat(ru.username, ' ') FROM conversation_members r JOIN user ru ON ru.user_id = r.user_id
+		WHERE r.conversation_id = m.conversation_id AND r.user_id != m.sender_id AND r.last_read_at >= m.created_at)
+FROM messages m JOIN user u ON u.user_id = m.sender_id`
+
+// ConversationRepository handles private conversations and their messages
+type ConversationRepository struct {
+	DB *sql.DB
+}
+
+// NewConversationRepository creates a new ConversationRepository
+func NewConversationRepository(db *sql.DB) *ConversationRepository {
+	return &ConversationRepository{DB: db}
+}
+
+// Create starts a conversation between the creator and the other members.
+// With a single other member it is one-to-one, and the pair's existing
+// conversation is returned instead if they already have one. Only group
+// conversations have a title. Nobody who blocked the creator, or whom the
+// creator blocked, can be added. It returns the conversation's ID and
+// whether it is new.
+func (r *ConversationRepository) Create(creatorID string, memberIDs []string, title string) (string, bool, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback()
+
+	for _, memberID := range memberIDs {
+		var blocked bool
+		err = tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?))`,
+			creatorID, memberID, memberID, creatorID,
+		).Scan(&blocked)
+		if err != nil {
+			return "", false, err
+		}
+		if blocked {
+			return "", false, config.ErrMessagingBlocked
+		}
+	}
+
+	var directKey any
+	if len(memberIDs) == 1 {
+		pair := []string{creatorID, memberIDs[0]}
+		slices.Sort(pair)
+		directKey = strings.Join(pair, ":")
+
+		var conversationID string
+		err = tx.QueryRow("SELECT conversation_id FROM conversations WHERE direct_key = ?", directKey).Scan(&conversationID)
+		if err == nil {
+			return conversationID, false, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", false, err
+		}
+		title = ""
+	}
+
+	conversationID := utils.GenerateUUID()
+	now := time.Now()
+	_, err = tx.Exec(
+		"INSERT INTO conversations (conversation_id, title, direct_key, created_by, created_at) VALUES (?, ?, ?, ?, ?)",
+		conversationID, title, directKey, creatorID, now,
+	)
+	if err != nil {
+		return "", false, err
+	}
+
+	// The creator has read everything so far
+	_, err = tx.Exec(
+		"INSERT INTO conversation_members (conversation_id, user_id, joined_at, last_read_at) VALUES (?, ?, ?, ?)",
+		conversationID, creatorID, now, now,
+	)
+	if err != nil {
+		return "", false, err
+	}
+	for _, memberID := range memberIDs {
+		_, err = tx.Exec(
+			"INSERT INTO conversation_members (conversation_id, user_id, joined_at) VALUES (?, ?, ?)",
+			conversationID, memberID, now,
+		)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return "", false, err
+	}
+
+	return conversationID, true, nil
+}
+
+// Get retrieves one of a user's conversations with its members
+func (r *ConversationRepository) Get(conversationID, userID string) (*models.Conversation, error) {
+	conversation, err := scanConversation(r.DB.QueryRow(
+		conversationSelect+" WHERE c.conversation_id = ?",
+		userID, userID, userID, conversationID,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrConversationNotFound
+		}
+		return nil, err
+	}
+
+	conversation.Members, err = r.listMembers(conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+// List returns a page of a user's conversations, most recently active first
+func (r *ConversationRepository) List(userID string, limit, offset int) ([]models.Conversation, error) {
+	rows, err := r.DB.Query(
+		conversationSelect+`
+		ORDER BY COALESCE(c.last_message_at, c.created_at) DESC
+		LIMIT ? OFFSET ?`,
+		userID, userID, userID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conversation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range conversations {
+		conversations[i].Members, err = r.listMembers(conversations[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conversations, nil
+}
+
+// ListMemberIDs returns the IDs of the members of a conversation
+func (r *ConversationRepository) ListMemberIDs(conversationID string) ([]string, error) {
+	rows, err := r.DB.Query("SELECT user_id FROM conversation_members WHERE conversation_id = ?", conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// AddMessage sends a message to a conversation the sender is a member of.
+// In a one-to-one conversation, nobody can send once either has blocked the
+// other. Sending counts as having read the conversation.
+func (r *ConversationRepository) AddMessage(conversationID, senderID, content string) (*models.Message, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var group bool
+	err = tx.QueryRow(
+		`SELECT c.direct_key IS NULL FROM conversations c
+		JOIN conversation_members cm ON cm.conversation_id = c.conversation_id AND cm.user_id = ?
+		WHERE c.conversation_id = ?`,
+		senderID, conversationID,
+	).Scan(&group)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrConversationNotFound
+		}
+		return nil, err
+	}
+
+	if !group {
+		var blocked bool
+		err = tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM conversation_members cm
+			JOIN user_blocks b ON (b.blocker_id = cm.user_id AND b.blocked_id = ?) OR (b.blocker_id = ? AND b.blocked_id = cm.user_id)
+			WHERE cm.conversation_id = ? AND cm.user_id != ?)`,
+			senderID, senderID, conversationID, senderID,
+		).Scan(&blocked)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, config.ErrMessagingBlocked
+		}
+	}
+
+	messageID := utils.GenerateUUID()
+	now := time.Now()
+	_, err = tx.Exec(
+		"INSERT INTO messages (message_id, conversation_id, sender_id, content, created_at) VALUES (?, ?, ?, ?, ?)",
+		messageID, conversationID, senderID, content, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE conversations SET last_message_at = ? WHERE conversation_id = ?", now, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE conversation_members SET last_read_at = ? WHERE conversation_id = ? AND user_id = ?",
+		now, conversationID, senderID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return scanMessage(r.DB.QueryRow(messageSelect+" WHERE m.message_id = ?", messageID))
+}
+
+// ListMessages returns a page of a conversation's messages, newest first.
+// Messages from users the member muted or blocked are left out.
+func (r *ConversationRepository) ListMessages(conversationID, userID string, limit, offset int) ([]models.Message, error) {
+	if err := r.checkMember(conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(
+		messageSelect+`
+		WHERE m.conversation_id = ? AND m.sender_id `+hiddenAuthorsClause+`
+		ORDER BY m.created_at DESC
+		LIMIT ? OFFSET ?`,
+		conversationID, userID, userID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *message)
+	}
+
+	return messages, rows.Err()
+}
+
+// MarkRead records that a member has read everything in a conversation and
+// returns the time it was recorded at
+func (r *ConversationRepository) MarkRead(conversationID, userID string) (time.Time, error) {
+	now := time.Now()
+	result, err := r.DB.Exec(
+		"UPDATE conversation_members SET last_read_at = ? WHERE conversation_id = ? AND user_id = ?",
+		now, conversationID, userID,
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return now, conversationAffected(result)
+}
+
+// SetMuted mutes or unmutes a conversation for one of its members
+func (r *ConversationRepository) SetMuted(conversationID, userID string, muted bool) error {
+	result, err := r.DB.Exec(
+		"UPDATE conversation_members SET muted = ? WHERE conversation_id = ? AND user_id = ?",
+		muted, conversationID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return conversationAffected(result)
+}
+
+// CountUnread counts a user's unread messages in the conversations they have not muted
+func (r *ConversationRepository) CountUnread(userID string) (int, error) {
+	var count int
+	err := r.DB.QueryRow(
+		`SELECT COUNT(*) FROM messages m
+		JOIN conversation_members me ON me.conversation_id = m.conversation_id AND me.user_id = ?
+		WHERE me.muted = 0 AND `+unreadClause,
+		userID, userID, userID,
+	).Scan(&count)
+	return count, err
+}
+
+// checkMember reports ErrConversationNotFound unless the user is a member of the conversation
+func (r *ConversationRepository) checkMember(conversationID, userID string) error {
+	var member bool
+	err := r.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM conversation_members WHERE conversation_id = ? AND user_id = ?)",
+		conversationID, userID,
+	).Scan(&member)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return config.ErrConversationNotFound
+	}
+	return nil
+}
+
+// listMembers returns the members of a conversation, by username
+func (r *ConversationRepository) listMembers(conversationID string) ([]models.ConversationMember, error) {
+	rows, err := r.DB.Query(
+		`SELECT cm.user_id, u.username, cm.last_read_at FROM conversation_members cm
+		JOIN user u ON u.user_id = cm.user_id
+		WHERE cm.conversation_id = ?
+		ORDER BY u.username_canonical`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ConversationMember{}
+	for rows.Next() {
+		var member models.ConversationMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.LastReadAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// conversationAffected reports ErrConversationNotFound when an update
+// matched no membership
+func conversationAffected(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrConversationNotFound
+	}
+	return nil
+}
+
+// scanConversation scans a row produced by conversationSelect
+func scanConversation(row scanner) (*models.Conversation, error) {
+	var conversation models.Conversation
+	err := row.Scan(&conversation.ID, &conversation.Title, &conversation.Group, &conversation.CreatedAt, &conversation.LastMessageAt,
+		&conversation.Muted, &conversation.Unread)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Members = []models.ConversationMember{}
+	return &conversation, nil
+}
+
+// scanMessage scans a row produced by messageSelect
+func scanMessage(row scanner) (*models.Message, error) {
+	var message models.Message
+	var readBy *string
+	err := row.Scan(&message.ID, &message.ConversationID, &message.SenderID, &message.Sender, &message.Content, &message.CreatedAt, &readBy)
+	if err != nil {
+		return nil, err
+	}
+	message.ReadBy = []string{}
+	if readBy != nil {
+		message.ReadBy = strings.Fields(*readBy)
+	}
+	return &message, nil
+}
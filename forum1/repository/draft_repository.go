@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// draftSelect selects an unpublished post with its mentioned usernames
+const draftSelect = `SELECT post_id, user_id, category_id, content, created_at, updated_at, publish_at,
+	(SELECT group_concat(mu.username, ' ') FROM mentions m JOIN user mu ON mu.user_id = m.user_id WHERE m.post_id = posts.post_id)
+FROM posts`
+
+// DraftRepository handles unpublished posts and their publication
+type DraftRepository struct {
+	DB *sql.DB
+}
+
+// NewDraftRepository creates a new DraftRepository
+func NewDraftRepository(db *sql.DB) *DraftRepository {
+	return &DraftRepository{DB: db}
+}
+
+// Create starts a new draft
+func (r *DraftRepository) Create(userID, categoryID, content string, attachments []string) (*models.Draft, error) {
+	if err := r.checkCategory(categoryID); err != nil {
+		return nil, err
+	}
+
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	draftID := utils.GenerateUUID()
+	_, err = tx.Exec(
+		"INSERT INTO posts (post_id, user_id, category_id, content, created_at, draft) VALUES (?, ?, ?, ?, ?, 1)",
+		draftID, userID, categoryID, content, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertAttachments(tx, "post_id", draftID, attachments); err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.Get(draftID, userID)
+}
+
+// Get retrieves one of the user's drafts. Drafts of other users are not found.
+func (r *DraftRepository) Get(draftID, userID string) (*models.Draft, error) {
+	draft, err := scanDraft(r.DB.QueryRow(draftSelect+" WHERE post_id = ? AND user_id = ? AND draft = 1", draftID, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrDraftNotFound
+		}
+		return nil, err
+	}
+
+	return draft, nil
+}
+
+// List returns a page of the user's drafts, most recently saved first
+func (r *DraftRepository) List(userID string, limit, offset int) ([]models.Draft, error) {
+	rows, err := r.DB.Query(
+		draftSelect+`
+		WHERE user_id = ? AND draft = 1
+		ORDER BY COALESCE(updated_at, created_at) DESC
+		LIMIT ? OFFSET ?`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []models.Draft{}
+	for rows.Next() {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, *draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+// Update saves the category and content of a draft. Drafts keep no edit
+// history, so autosaving as often as needed is cheap.
+func (r *DraftRepository) Update(draftID, userID, categoryID, content string) error {
+	if err := r.checkCategory(categoryID); err != nil {
+		return err
+	}
+
+	result, err := r.DB.Exec(
+		"UPDATE posts SET category_id = ?, content = ?, updated_at = ? WHERE post_id = ? AND user_id = ? AND draft = 1",
+		categoryID, content, time.Now(), draftID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return draftAffected(result)
+}
+
+// Discard permanently deletes a draft. Nobody else has seen it, so it does
+// not go through the trash.
+func (r *DraftRepository) Discard(draftID, userID string) error {
+	result, err := r.DB.Exec("DELETE FROM posts WHERE post_id = ? AND user_id = ? AND draft = 1", draftID, userID)
+	if err != nil {
+		return err
+	}
+
+	return draftAffected(result)
+}
+
+// Publish makes a draft visible in the feed right away. The post counts as
+// created when it is published.
+func (r *DraftRepository) Publish(draftID, userID string) error {
+	result, err := r.DB.Exec(
+		"UPDATE posts SET draft = 0, publish_at = NULL, created_at = ?, updated_at = NULL WHERE post_id = ? AND user_id = ? AND draft = 1",
+		time.Now(), draftID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return draftAffected(result)
+}
+
+// Schedule sets the time a draft will be published at. A nil publishAt
+// cancels the schedule and keeps the draft.
+func (r *DraftRepository) Schedule(draftID, userID string, publishAt *time.Time) error {
+	// Timestamps are compared as text, so store it in the same zone as time.Now()
+	if publishAt != nil {
+		local := publishAt.Local()
+		publishAt = &local
+	}
+
+	result, err := r.DB.Exec(
+		"UPDATE posts SET publish_at = ? WHERE post_id = ? AND user_id = ? AND draft = 1",
+		publishAt, draftID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return draftAffected(result)
+}
+
+// PublishScheduled publishes every draft whose scheduled time has come and
+// returns the IDs of the published posts. Drafts of banned or suspended
+// authors wait until the restriction is over.
+func (r *DraftRepository) PublishScheduled() ([]string, error) {
+	now := time.Now()
+	rows, err := r.DB.Query(
+		`UPDATE posts SET draft = 0, created_at = ?, publish_at = NULL, updated_at = NULL
+		WHERE draft = 1 AND publish_at IS NOT NULL AND publish_at <= ?
+			AND NOT EXISTS (SELECT 1 FROM user_sanctions s
+				WHERE s.user_id = posts.user_id AND s.revoked_at IS NULL
+					AND (s.sanction_type = 'ban' OR (s.sanction_type = 'suspension' AND s.expires_at > ?)))
+		RETURNING post_id`,
+		now, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	postIDs := []string{}
+	for rows.Next() {
+		var postID string
+		if err := rows.Scan(&postID); err != nil {
+			return nil, err
+		}
+		postIDs = append(postIDs, postID)
+	}
+
+	return postIDs, rows.Err()
+}
+
+// checkCategory reports an unknown category as ErrCategoryNotFound
+func (r *DraftRepository) checkCategory(categoryID string) error {
+	var exists bool
+	err := r.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM categories WHERE category_id = ?)", categoryID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return config.ErrCategoryNotFound
+	}
+	return nil
+}
+
+// draftAffected reports an update that matched no draft as ErrDraftNotFound
+func draftAffected(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrDraftNotFound
+	}
+	return nil
+}
+
+// scanDraft scans a row produced by draftSelect
+func scanDraft(row scanner) (*models.Draft, error) {
+	var draft models.Draft
+	var mentioned *string
+	err := row.Scan(&draft.ID, &draft.UserID, &draft.CategoryID, &draft.Content, &draft.CreatedAt, &draft.UpdatedAt, &draft.PublishAt, &mentioned)
+	if err != nil {
+		return nil, err
+	}
+	draft.Attachments = []models.Upload{}
+	draft.ContentHTML = utils.RenderMarkdown(draft.Content, splitMentioned(mentioned))
+	return &draft, nil
+}
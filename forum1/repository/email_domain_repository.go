@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+)
+
+// EmailDomainRepository handles the email domain rules managed by admins
+type EmailDomainRepository struct {
+	DB *sql.DB
+}
+
+// NewEmailDomainRepository creates a new EmailDomainRepository
+func NewEmailDomainRepository(db *sql.DB) *EmailDomainRepository {
+	return &EmailDomainRepository{DB: db}
+}
+
+// List returns every rule, ordered by domain
+func (r *EmailDomainRepository) List() ([]models.EmailDomainRule, error) {
+	rows, err := r.DB.Query("SELECT domain, rule_type, created_by, created_at FROM email_domain_rules ORDER BY domain")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.EmailDomainRule{}
+	for rows.Next() {
+		var rule models.EmailDomainRule
+		if err := rows.Scan(&rule.Domain, &rule.RuleType, &rule.CreatedBy, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// Lists returns the allowed and denied domains
+func (r *EmailDomainRepository) Lists() (allow, deny []string, err error) {
+	rules, err := r.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.RuleType == config.EMAIL_DOMAIN_ALLOW {
+			allow = append(allow, rule.Domain)
+		} else {
+			deny = append(deny, rule.Domain)
+		}
+	}
+
+	return allow, deny, nil
+}
+
+// Upsert adds a rule or changes the type of an existing one
+func (r *EmailDomainRepository) Upsert(domain, ruleType, createdBy string) (*models.EmailDomainRule, error) {
+	rule := &models.EmailDomainRule{
+		Domain:    domain,
+		RuleType:  ruleType,
+		CreatedBy: &createdBy,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := r.DB.Exec(
+		`INSERT INTO email_domain_rules (domain, rule_type, created_by, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(domain) DO UPDATE SET rule_type = excluded.rule_type, created_by = excluded.created_by, created_at = excluded.created_at`,
+		rule.Domain, rule.RuleType, createdBy, rule.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// Delete removes a rule
+func (r *EmailDomainRepository) Delete(domain string) error {
+	result, err := r.DB.Exec("DELETE FROM email_domain_rules WHERE domain = ?", domain)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrDomainRuleNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// InviteRepository handles invite codes for invite-only registration
+type InviteRepository struct {
+	DB *sql.DB
+}
+
+// NewInviteRepository creates a new InviteRepository
+func NewInviteRepository(db *sql.DB) *InviteRepository {
+	return &InviteRepository{DB: db}
+}
+
+// Create generates a new invite code
+func (r *InviteRepository) Create(createdBy string, maxUses int, expiresAt time.Time) (*models.Invite, error) {
+	code, err := utils.GenerateInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &models.Invite{
+		Code:      code,
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+
+	_, err = r.DB.Exec(
+		"INSERT INTO invite_codes (code, created_by, max_uses, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		invite.Code, invite.CreatedBy, invite.MaxUses, invite.CreatedAt, invite.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// ListByCreator returns the invites a user created, newest first
+func (r *InviteRepository) ListByCreator(userID string) ([]models.Invite, error) {
+	rows, err := r.DB.Query(
+		"SELECT code, created_by, max_uses, uses, created_at, expires_at, revoked_at FROM invite_codes WHERE created_by = ? ORDER BY created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.Invite{}
+	for rows.Next() {
+		var invite models.Invite
+		err := rows.Scan(&invite.Code, &invite.CreatedBy, &invite.MaxUses, &invite.Uses, &invite.CreatedAt, &invite.ExpiresAt, &invite.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, rows.Err()
+}
+
+// Redeem uses up one use of an invite code. The code is only consumed if it
+// is unrevoked, unexpired and below its usage limit.
+func (r *InviteRepository) Redeem(code string) error {
+	result, err := r.DB.Exec(
+		"UPDATE invite_codes SET uses = uses + 1 WHERE code = ? AND revoked_at IS NULL AND expires_at > ? AND uses < max_uses",
+		normalizeInviteCode(code), time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrInvalidInvite
+	}
+
+	return nil
+}
+
+// Release gives back a use when the registration that redeemed it failed
+func (r *InviteRepository) Release(code string) error {
+	_, err := r.DB.Exec("UPDATE invite_codes SET uses = uses - 1 WHERE code = ? AND uses > 0", normalizeInviteCode(code))
+	return err
+}
+
+// Revoke disables an invite code. Only its creator or an admin may revoke it.
+func (r *InviteRepository) Revoke(code, userID string, isAdmin bool) error {
+	result, err := r.DB.Exec(
+		"UPDATE invite_codes SET revoked_at = ? WHERE code = ? AND revoked_at IS NULL AND (created_by = ? OR ?)",
+		time.Now(), normalizeInviteCode(code), userID, isAdmin,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrInviteNotFound
+	}
+
+	return nil
+}
+
+// normalizeInviteCode makes codes case-insensitive and tolerant of stray spaces
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
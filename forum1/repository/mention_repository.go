@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/utils"
+)
+
+// MentionRepository records which users are @mentioned in posts and comments
+type MentionRepository struct {
+	DB *sql.DB
+}
+
+// NewMentionRepository creates a new MentionRepository
+func NewMentionRepository(db *sql.DB) *MentionRepository {
+	return &MentionRepository{DB: db}
+}
+
+// Sync records the users mentioned in the content of the post or comment in
+// targetColumn, replacing its earlier mentions. It returns their usernames,
+// for rendering, and the IDs of the users who were not mentioned before, for
+// notifying.
+func (r *MentionRepository) Sync(targetColumn, targetID, authorID, content string) ([]string, []string, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	usernames, userIDs, err := resolveMentions(tx, authorID, content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing, err := listMentioned(tx, targetColumn, targetID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Forget the users no longer mentioned
+	for _, userID := range existing {
+		if slices.Contains(userIDs, userID) {
+			continue
+		}
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM mentions WHERE %s = ? AND user_id = ?", targetColumn), targetID, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Add the newly mentioned ones
+	added := []string{}
+	for _, userID := range userIDs {
+		if slices.Contains(existing, userID) {
+			continue
+		}
+		_, err = tx.Exec(
+			fmt.Sprintf("INSERT INTO mentions (user_id, %s, created_at) VALUES (?, ?, ?)", targetColumn),
+			userID, targetID, time.Now(),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		added = append(added, userID)
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return usernames, added, nil
+}
+
+// Resolve returns the usernames of the users that content by authorID
+// would mention, without recording anything
+func (r *MentionRepository) Resolve(authorID, content string) ([]string, error) {
+	usernames, _, err := resolveMentions(r.DB, authorID, content)
+	return usernames, err
+}
+
+// ListMentioned returns the IDs of the users mentioned in the post or comment in targetColumn
+func (r *MentionRepository) ListMentioned(targetColumn, targetID string) ([]string, error) {
+	return listMentioned(r.DB, targetColumn, targetID)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// resolveMentions looks up the users mentioned in content by authorID:
+// active users who have not blocked the author, up to MAX_MENTIONS of them.
+// It returns their usernames and IDs.
+func resolveMentions(db querier, authorID, content string) ([]string, []string, error) {
+	var usernames, userIDs []string
+	for _, written := range utils.ParseMentions(content) {
+		if len(userIDs) == config.MAX_MENTIONS {
+			break
+		}
+
+		var userID, username string
+		err := db.QueryRow(
+			`SELECT u.user_id, u.username FROM user u
+			WHERE u.username_canonical = ? AND u.user_id != ? AND u.status = ?
+				AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = u.user_id AND b.blocked_id = ?)`,
+			utils.CanonicalUsername(written), config.DELETED_USER_ID, config.USER_STATUS_ACTIVE, authorID,
+		).Scan(&userID, &username)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		usernames = append(usernames, username)
+		userIDs = append(userIDs, userID)
+	}
+
+	return usernames, userIDs, nil
+}
+
+// listMentioned returns the IDs of the users mentioned in the post or comment in targetColumn
+func listMentioned(db querier, targetColumn, targetID string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT user_id FROM mentions WHERE %s = ? ORDER BY created_at", targetColumn), targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// splitMentioned turns the space separated usernames selected alongside a
+// post, comment or draft into a list
+func splitMentioned(usernames *string) []string {
+	if usernames == nil {
+		return nil
+	}
+	return strings.Fields(*usernames)
+}
@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// notificationSelect selects a notification with the actor's username
+const notificationSelect = `SELECT n.notification_id, n.user_id, n.notification_type, n.actor_id, u.username, n.post_id, n.comment_id,
+	COALESCE(n.reaction_type, 0), n.message, n.created_at, n.read_at
+FROM notifications n LEFT JOIN user u ON u.user_id = n.actor_id`
+
+// NotificationRepository handles users' notifications and their preferences
+type NotificationRepository struct {
+	DB *sql.DB
+}
+
+// NewNotificationRepository creates a new NotificationRepository
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{DB: db}
+}
+
+// Create records a notification unless the recipient caused it, turned its
+// type off, or muted or blocked the actor. Moderator actions are delivered
+// regardless of mutes. A reaction replaces the actor's earlier unread
+// reaction notification on the same content. It returns nil when nothing was
+// recorded.
+func (r *NotificationRepository) Create(notification models.Notification) (*models.Notification, error) {
+	if notification.ActorID != nil && *notification.ActorID == notification.UserID {
+		return nil, nil
+	}
+
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if notification.Type == config.NOTIFICATION_REACTION && notification.ActorID != nil {
+		targetColumn, targetID := "post_id", notification.PostID
+		if notification.CommentID != nil {
+			targetColumn, targetID = "comment_id", notification.CommentID
+		}
+		_, err = tx.Exec(
+			"DELETE FROM notifications WHERE user_id = ? AND actor_id = ? AND notification_type = ? AND read_at IS NULL AND "+reactionTargetClause(targetColumn),
+			notification.UserID, *notification.ActorID, config.NOTIFICATION_REACTION, targetID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var reactionType any
+	if notification.ReactionType != 0 {
+		reactionType = notification.ReactionType
+	}
+
+	notification.ID = utils.GenerateUUID()
+	notification.CreatedAt = time.Now()
+	result, err := tx.Exec(
+		`INSERT INTO notifications (notification_id, user_id, actor_id, notification_type, post_id, comment_id, reaction_type, message, created_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM notification_preferences WHERE user_id = ? AND notification_type = ? AND enabled = 0)
+			AND (? = ? OR ? IS NULL OR ? `+hiddenAuthorsClause+`)`,
+		notification.ID, notification.UserID, notification.ActorID, notification.Type, notification.PostID, notification.CommentID,
+		reactionType, notification.Message, notification.CreatedAt,
+		notification.UserID, notification.Type,
+		notification.Type, config.NOTIFICATION_MODERATION,
+		notification.ActorID, notification.ActorID, notification.UserID, notification.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, nil
+	}
+
+	return r.get(notification.ID)
+}
+
+// CreateForPost records a notification for the author of a post
+func (r *NotificationRepository) CreateForPost(postID string, notification models.Notification) (*models.Notification, error) {
+	err := r.DB.QueryRow("SELECT user_id FROM posts WHERE post_id = ?", postID).Scan(&notification.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrPostNotFound
+		}
+		return nil, err
+	}
+
+	notification.PostID = &postID
+	return r.Create(notification)
+}
+
+// CreateForComment records a notification for the author of a comment. It
+// refers to that comment unless the notification names another one, such as
+// a reply.
+func (r *NotificationRepository) CreateForComment(commentID string, notification models.Notification) (*models.Notification, error) {
+	var postID string
+	err := r.DB.QueryRow("SELECT user_id, post_id FROM comments WHERE comment_id = ?", commentID).Scan(&notification.UserID, &postID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrCommentNotFound
+		}
+		return nil, err
+	}
+
+	notification.PostID = &postID
+	if notification.CommentID == nil {
+		notification.CommentID = &commentID
+	}
+	return r.Create(notification)
+}
+
+// CreateForMention notifies a user mentioned in the post or comment in
+// targetColumn, with its author as the actor
+func (r *NotificationRepository) CreateForMention(userID, targetColumn, targetID string) (*models.Notification, error) {
+	notification := models.Notification{UserID: userID, Type: config.NOTIFICATION_MENTION}
+
+	var authorID, postID string
+	var err error
+	errNotFound := config.ErrPostNotFound
+	if targetColumn == "post_id" {
+		postID = targetID
+		err = r.DB.QueryRow("SELECT user_id FROM posts WHERE post_id = ?", targetID).Scan(&authorID)
+	} else {
+		notification.CommentID = &targetID
+		errNotFound = config.ErrCommentNotFound
+		err = r.DB.QueryRow("SELECT user_id, post_id FROM comments WHERE comment_id = ?", targetID).Scan(&authorID, &postID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNotFound
+		}
+		return nil, err
+	}
+
+	notification.ActorID = &authorID
+	notification.PostID = &postID
+	return r.Create(notification)
+}
+
+// RemoveUnreadReaction deletes an actor's unread reaction notification on
+// the post or comment in targetColumn once the reaction is taken back
+func (r *NotificationRepository) RemoveUnreadReaction(actorID, targetColumn, targetID string) error {
+	_, err := r.DB.Exec(
+		"DELETE FROM notifications WHERE actor_id = ? AND notification_type = ? AND read_at IS NULL AND "+reactionTargetClause(targetColumn),
+		actorID, config.NOTIFICATION_REACTION, targetID,
+	)
+	return err
+}
+
+// reactionTargetClause matches reaction notifications about a post itself
+// (not its comments) or about a comment
+func reactionTargetClause(targetColumn string) string {
+	if targetColumn == "post_id" {
+		return "post_id = ? AND comment_id IS NULL"
+	}
+	return "comment_id = ?"
+}
+
+// get retrieves a notification by its ID
+func (r *NotificationRepository) get(notificationID string) (*models.Notification, error) {
+	notification, err := scanNotification(r.DB.QueryRow(notificationSelect+" WHERE n.notification_id = ?", notificationID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrNotificationNotFound
+		}
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// List returns a page of a user's notifications, newest first
+func (r *NotificationRepository) List(userID string, unreadOnly bool, limit, offset int) ([]models.Notification, error) {
+	rows, err := r.DB.Query(
+		notificationSelect+`
+		WHERE n.user_id = ? AND (? = 0 OR n.read_at IS NULL)
+		ORDER BY n.created_at DESC
+		LIMIT ? OFFSET ?`,
+		userID, unreadOnly, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, *notification)
+	}
+
+	return notifications, rows.Err()
+}
+
+// CountUnread returns how many notifications a user has not read yet
+func (r *NotificationRepository) CountUnread(userID string) (int, error) {
+	var count int
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks one of a user's notifications as read. Marking it again
+// keeps the original read time.
+func (r *NotificationRepository) MarkRead(notificationID, userID string) error {
+	result, err := r.DB.Exec(
+		"UPDATE notifications SET read_at = COALESCE(read_at, ?) WHERE notification_id = ? AND user_id = ?",
+		time.Now(), notificationID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrNotificationNotFound
+	}
+
+	return nil
+}
+
+// MarkAllRead marks every unread notification of a user as read and returns how many there were
+func (r *NotificationRepository) MarkAllRead(userID string) (int64, error) {
+	result, err := r.DB.Exec("UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL", time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetPreferences returns whether each notification type is delivered to a user
+func (r *NotificationRepository) GetPreferences(userID string) (models.NotificationPreferences, error) {
+	preferences := models.NotificationPreferences{}
+	for _, notificationType := range config.NOTIFICATION_TYPES {
+		preferences[notificationType] = true
+	}
+
+	rows, err := r.DB.Query("SELECT notification_type, enabled FROM notification_preferences WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notificationType string
+		var enabled bool
+		if err := rows.Scan(&notificationType, &enabled); err != nil {
+			return nil, err
+		}
+		preferences[notificationType] = enabled
+	}
+
+	return preferences, rows.Err()
+}
+
+// SetPreferences turns the given notification types on or off for a user.
+// Types that are not mentioned keep their current setting.
+func (r *NotificationRepository) SetPreferences(userID string, preferences models.NotificationPreferences) error {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for notificationType, enabled := range preferences {
+		_, err = tx.Exec(
+			`INSERT INTO notification_preferences (user_id, notification_type, enabled) VALUES (?, ?, ?)
+			ON CONFLICT(user_id, notification_type) DO UPDATE SET enabled = excluded.enabled`,
+			userID, notificationType, enabled,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// scanNotification scans a row produced by notificationSelect. Moderators
+// acting on a user's account or content stay anonymous.
+func scanNotification(row scanner) (*models.Notification, error) {
+	var notification models.Notification
+	err := row.Scan(&notification.ID, &notification.UserID, &notification.Type, &notification.ActorID, &notification.Actor,
+		&notification.PostID, &notification.CommentID, &notification.ReactionType, &notification.Message,
+		&notification.CreatedAt, &notification.ReadAt)
+	if err != nil {
+		return nil, err
+	}
+	if notification.Type == config.NOTIFICATION_MODERATION {
+		notification.ActorID = nil
+		notification.Actor = nil
+	}
+	return &notification, nil
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// postSelect selects a post with its author, reaction/comment counts and
+// mentioned usernames
+const postSelect = `SELECT p.post_id, p.user_id, u.username, p.category_id, p.content, p.created_at, p.updated_at, p.deleted_at,
+	(SELECT COUNT(*) FROM reactions WHERE post_id = p.post_id AND reaction_type = 1),
+	(SELECT COUNT(*) FROM reactions WHERE post_id = p.post_id AND reaction_type = 2),
+	(SELECT COUNT(*) FROM comments WHERE post_id = p.post_id AND deleted_at IS NULL),
+	(SELECT group_concat(mu.username, ' ') FROM mentions m JOIN user mu ON mu.user_id = m.user_id WHERE m.post_id = p.post_id)
+FROM posts p JOIN user u ON u.user_id = p.user_id`
+
+// PostRepository handles post-related database operations
+type PostRepository struct {
+	DB *sql.DB
+}
+
+// NewPostRepository creates a new PostRepository
+func NewPostRepository(db *sql.DB) *PostRepository {
+	return &PostRepository{DB: db}
+}
+
+// Create adds a new post
+func (r *PostRepository) Create(userID string, creation models.PostCreation, attachments []string) (*models.Post, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Check the category exists
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM categories WHERE category_id = ?", creation.CategoryID).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, config.ErrCategoryNotFound
+	}
+
+	postID := utils.GenerateUUID()
+	createdAt := time.Now()
+
+	_, err = tx.Exec(
+		"INSERT INTO posts (post_id, user_id, category_id, content, created_at) VALUES (?, ?, ?, ?, ?)",
+		postID, userID, creation.CategoryID, creation.Content, createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertAttachments(tx, "post_id", postID, attachments); err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(postID)
+}
+
+// GetByID retrieves a published post by its ID. Deleted posts are returned as
+// placeholders; drafts are not found.
+func (r *PostRepository) GetByID(postID string) (*models.Post, error) {
+	post, err := scanPost(r.DB.QueryRow(postSelect+" WHERE p.post_id = ? AND p.draft = 0", postID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrPostNotFound
+		}
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// List returns a page of the feed, newest first. Drafts, deleted posts and
+// posts by authors the viewer has muted or blocked are left out. An empty categoryID
+// lists every category.
+func (r *PostRepository) List(viewerID, categoryID string, limit, offset int) ([]models.Post, error) {
+	rows, err := r.DB.Query(
+		postSelect+`
+		WHERE p.user_id `+hiddenAuthorsClause+`
+			AND p.deleted_at IS NULL AND p.draft = 0
+			AND (? = '' OR p.category_id = ?)
+		ORDER BY p.created_at DESC
+		LIMIT ? OFFSET ?`,
+		viewerID, viewerID, categoryID, categoryID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []models.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *post)
+	}
+
+	return posts, rows.Err()
+}
+
+// ListCategories returns every category in display order
+func (r *PostRepository) ListCategories() ([]models.Category, error) {
+	rows, err := r.DB.Query("SELECT category_id, category_number, category_name FROM categories ORDER BY category_number")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(&category.ID, &category.Number, &category.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPost scans a row produced by postSelect, replacing the content of a
+// deleted post with a placeholder
+func scanPost(row scanner) (*models.Post, error) {
+	var post models.Post
+	var deletedAt *time.Time
+	var mentioned *string
+	err := row.Scan(&post.ID, &post.UserID, &post.Author, &post.CategoryID, &post.Content, &post.CreatedAt, &post.UpdatedAt, &deletedAt,
+		&post.Likes, &post.Dislikes, &post.CommentCount, &mentioned)
+	if err != nil {
+		return nil, err
+	}
+	post.Attachments = []models.Upload{}
+	if deletedAt != nil {
+		post.Deleted = true
+		post.Content = config.DELETED_CONTENT_PLACEHOLDER
+		return &post, nil
+	}
+	post.ContentHTML = utils.RenderMarkdown(post.Content, splitMentioned(mentioned))
+	return &post, nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// ProfileRepository handles user profile database operations
+type ProfileRepository struct {
+	DB *sql.DB
+}
+
+// NewProfileRepository creates a new ProfileRepository
+func NewProfileRepository(db *sql.DB) *ProfileRepository {
+	return &ProfileRepository{DB: db}
+}
+
+// GetByUserID retrieves a user's profile, returning an empty profile if none was saved yet
+func (r *ProfileRepository) GetByUserID(userID string) (*models.UserProfile, error) {
+	profile := models.UserProfile{UserID: userID}
+
+	err := r.DB.QueryRow(
+		"SELECT display_name, bio, location, website, avatar_url, updated_at FROM user_profile WHERE user_id = ?",
+		userID,
+	).Scan(&profile.DisplayName, &profile.Bio, &profile.Location, &profile.Website, &profile.AvatarURL, &profile.UpdatedAt)
+
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// Upsert creates or replaces a user's profile
+func (r *ProfileRepository) Upsert(profile models.UserProfile) (*models.UserProfile, error) {
+	now := time.Now()
+
+	_, err := r.DB.Exec(
+		`INSERT INTO user_profile (user_id, display_name, bio, location, website, avatar_url, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			display_name = excluded.display_name,
+			bio = excluded.bio,
+			location = excluded.location,
+			website = excluded.website,
+			avatar_url = excluded.avatar_url,
+			updated_at = excluded.updated_at`,
+		profile.UserID, profile.DisplayName, profile.Bio, profile.Location, profile.Website, profile.AvatarURL, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.UpdatedAt = &now
+	return &profile, nil
+}
+
+// GetPublicByUsername builds the public profile of a user, including activity stats
+func (r *ProfileRepository) GetPublicByUsername(username string) (*models.PublicProfile, error) {
+	var public models.PublicProfile
+	var userID string
+
+	err := r.DB.QueryRow(
+		"SELECT user_id, username, role, created_at FROM user WHERE username_canonical = ? AND user_id != ? AND status = ?",
+		utils.CanonicalUsername(username), config.DELETED_USER_ID, config.USER_STATUS_ACTIVE,
+	).Scan(&userID, &public.Username, &public.Role, &public.JoinedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	profile, err := r.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	public.Profile = *profile
+
+	stats, err := r.GetStats(userID)
+	if err != nil {
+		return nil, err
+	}
+	public.Stats = *stats
+
+	return &public, nil
+}
+
+// GetStats computes published post, comment and received reaction counts for
+// a user. Trashed content, and comments on trashed posts, are not counted.
+func (r *ProfileRepository) GetStats(userID string) (*models.ProfileStats, error) {
+	var stats models.ProfileStats
+
+	livePosts := `SELECT t.post_id FROM posts t WHERE t.user_id = ? AND ` + liveClause("posts")
+	liveComments := `SELECT t.comment_id FROM comments t
+		JOIN posts p ON p.post_id = t.post_id AND p.deleted_at IS NULL AND p.draft = 0
+		WHERE t.user_id = ? AND ` + liveClause("comments")
+
+	err := r.DB.QueryRow(
+		`SELECT
+			(SELECT COUNT(*) FROM (`+livePosts+`)),
+			(SELECT COUNT(*) FROM (`+liveComments+`))`,
+		userID, userID,
+	).Scan(&stats.PostCount, &stats.CommentCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reactions left by others on the user's live posts and comments
+	err = r.DB.QueryRow(
+		`SELECT
+			COALESCE(SUM(CASE WHEN reaction_type = 1 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN reaction_type = 2 THEN 1 ELSE 0 END), 0)
+		FROM reactions
+		WHERE user_id != ?
+			AND (post_id IN (`+livePosts+`)
+				OR comment_id IN (`+liveComments+`))`,
+		userID, userID, userID,
+	).Scan(&stats.LikesReceived, &stats.DislikesReceived)
+	if err != nil {
+		return nil, err
+	}
+	stats.ReactionsReceived = stats.LikesReceived + stats.DislikesReceived
+
+	return &stats, nil
+}
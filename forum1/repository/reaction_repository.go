@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// ReactionRepository handles likes and dislikes on posts and comments
+type ReactionRepository struct {
+	DB *sql.DB
+}
+
+// NewReactionRepository creates a new ReactionRepository
+func NewReactionRepository(db *sql.DB) *ReactionRepository {
+	return &ReactionRepository{DB: db}
+}
+
+// ReactToPost sets, switches or (when repeated) removes a user's reaction on a post
+func (r *ReactionRepository) ReactToPost(postID, userID string, reactionType int) (*models.ReactionCounts, error) {
+	return r.react("posts", "post_id", postID, userID, reactionType, config.ErrPostNotFound)
+}
+
+// ReactToComment sets, switches or (when repeated) removes a user's reaction on a comment
+func (r *ReactionRepository) ReactToComment(commentID, userID string, reactionType int) (*models.ReactionCounts, error) {
+	return r.react("comments", "comment_id", commentID, userID, reactionType, config.ErrCommentNotFound)
+}
+
+// react applies a reaction to a row of targetTable identified by targetColumn.
+// Users blocked by the content's author are rejected.
+func (r *ReactionRepository) react(targetTable, targetColumn, targetID, userID string, reactionType int, errNotFound error) (*models.ReactionCounts, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Comments on trashed posts are out of reach along with their post
+	live := liveClause(targetTable)
+	if targetTable == "comments" {
+		live += " AND EXISTS (SELECT 1 FROM posts p WHERE p.post_id = t.post_id AND p.deleted_at IS NULL AND p.draft = 0)"
+	}
+
+	// Look up the target and whether its author blocked the reacting user
+	var blocked bool
+	err = tx.QueryRow(
+		fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = t.user_id AND b.blocked_id = ?)
+		FROM %s t WHERE t.%s = ? AND %s`, targetTable, targetColumn, live),
+		userID, targetID,
+	).Scan(&blocked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNotFound
+		}
+		return nil, err
+	}
+	if blocked {
+		return nil, config.ErrBlocked
+	}
+
+	// Find the user's existing reaction
+	var reactionID string
+	var existingType int
+	err = tx.QueryRow(
+		fmt.Sprintf("SELECT reaction_id, reaction_type FROM reactions WHERE user_id = ? AND %s = ?", targetColumn),
+		userID, targetID,
+	).Scan(&reactionID, &existingType)
+
+	switch {
+	case err == sql.ErrNoRows:
+		// A concurrent first reaction by the same user is overwritten, not an error
+		_, err = tx.Exec(
+			fmt.Sprintf(`INSERT INTO reactions (reaction_id, user_id, reaction_type, %[1]s, created_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (user_id, %[1]s) WHERE %[1]s IS NOT NULL
+			DO UPDATE SET reaction_type = excluded.reaction_type, created_at = excluded.created_at`, targetColumn),
+			utils.GenerateUUID(), userID, reactionType, targetID, time.Now(),
+		)
+	case err != nil:
+		return nil, err
+	case existingType == reactionType:
+		// Repeating the same reaction takes it back
+		_, err = tx.Exec("DELETE FROM reactions WHERE reaction_id = ?", reactionID)
+	default:
+		_, err = tx.Exec("UPDATE reactions SET reaction_type = ?, created_at = ? WHERE reaction_id = ?", reactionType, time.Now(), reactionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the new totals
+	var counts models.ReactionCounts
+	err = tx.QueryRow(
+		fmt.Sprintf(`SELECT
+			COALESCE(SUM(CASE WHEN reaction_type = 1 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN reaction_type = 2 THEN 1 ELSE 0 END), 0),
+			COALESCE(MAX(CASE WHEN user_id = ? THEN reaction_type END), 0)
+		FROM reactions WHERE %s = ?`, targetColumn),
+		userID, targetID,
+	).Scan(&counts.Likes, &counts.Dislikes, &counts.Mine)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+)
+
+// hiddenAuthorsClause filters out authors the viewer has muted or blocked.
+// It expects the viewer's user ID twice as arguments; anonymous viewers pass "".
+const hiddenAuthorsClause = `NOT IN (
+	SELECT muted_id FROM user_mutes WHERE muter_id = ?
+	UNION SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)`
+
+// liveClause selects rows of targetTable, aliased t, that are neither deleted
+// nor, for posts, unpublished drafts
+func liveClause(targetTable string) string {
+	if targetTable == "posts" {
+		return "t.deleted_at IS NULL AND t.draft = 0"
+	}
+	return "t.deleted_at IS NULL"
+}
+
+// RelationshipRepository handles blocks and mutes between users
+type RelationshipRepository struct {
+	DB *sql.DB
+}
+
+// NewRelationshipRepository creates a new RelationshipRepository
+func NewRelationshipRepository(db *sql.DB) *RelationshipRepository {
+	return &RelationshipRepository{DB: db}
+}
+
+// Block stops blockedID from replying to, reacting to, mentioning or messaging blockerID
+func (r *RelationshipRepository) Block(blockerID, blockedID string) error {
+	if blockerID == blockedID {
+		return config.ErrInvalidRelation
+	}
+	_, err := r.DB.Exec(
+		"INSERT OR IGNORE INTO user_blocks (blocker_id, blocked_id, created_at) VALUES (?, ?, ?)",
+		blockerID, blockedID, time.Now(),
+	)
+	return err
+}
+
+// Unblock removes a block
+func (r *RelationshipRepository) Unblock(blockerID, blockedID string) error {
+	_, err := r.DB.Exec("DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?", blockerID, blockedID)
+	return err
+}
+
+// Mute hides mutedID's posts and comments from muterID's feeds
+func (r *RelationshipRepository) Mute(muterID, mutedID string) error {
+	if muterID == mutedID {
+		return config.ErrInvalidRelation
+	}
+	_, err := r.DB.Exec(
+		"INSERT OR IGNORE INTO user_mutes (muter_id, muted_id, created_at) VALUES (?, ?, ?)",
+		muterID, mutedID, time.Now(),
+	)
+	return err
+}
+
+// Unmute removes a mute
+func (r *RelationshipRepository) Unmute(muterID, mutedID string) error {
+	_, err := r.DB.Exec("DELETE FROM user_mutes WHERE muter_id = ? AND muted_id = ?", muterID, mutedID)
+	return err
+}
+
+// IsBlocked reports whether blockerID has blocked blockedID
+func (r *RelationshipRepository) IsBlocked(blockerID, blockedID string) (bool, error) {
+	var blocked bool
+	err := r.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?)",
+		blockerID, blockedID,
+	).Scan(&blocked)
+	return blocked, err
+}
+
+// Hides reports whether viewerID muted or blocked authorID, so their content
+// is left out of what viewerID sees
+func (r *RelationshipRepository) Hides(viewerID, authorID string) (bool, error) {
+	var visible bool
+	err := r.DB.QueryRow("SELECT ? "+hiddenAuthorsClause, authorID, viewerID, viewerID).Scan(&visible)
+	return !visible, err
+}
+
+// ListBlocked returns the users blocked by userID
+func (r *RelationshipRepository) ListBlocked(userID string) ([]models.UserRelation, error) {
+	return r.list("SELECT u.username, b.created_at FROM user_blocks b JOIN user u ON u.user_id = b.blocked_id WHERE b.blocker_id = ? ORDER BY b.created_at DESC", userID)
+}
+
+// ListMuted returns the users muted by userID
+func (r *RelationshipRepository) ListMuted(userID string) ([]models.UserRelation, error) {
+	return r.list("SELECT u.username, m.created_at FROM user_mutes m JOIN user u ON u.user_id = m.muted_id WHERE m.muter_id = ? ORDER BY m.created_at DESC", userID)
+}
+
+// list runs a username/created_at query
+func (r *RelationshipRepository) list(query, userID string) ([]models.UserRelation, error) {
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relations := []models.UserRelation{}
+	for rows.Next() {
+		var relation models.UserRelation
+		if err := rows.Scan(&relation.Username, &relation.CreatedAt); err != nil {
+			return nil, err
+		}
+		relations = append(relations, relation)
+	}
+
+	return relations, rows.Err()
+}
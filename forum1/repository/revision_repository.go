@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// RevisionRepository handles edits of posts and comments and their history
+type RevisionRepository struct {
+	DB *sql.DB
+}
+
+// NewRevisionRepository creates a new RevisionRepository
+func NewRevisionRepository(db *sql.DB) *RevisionRepository {
+	return &RevisionRepository{DB: db}
+}
+
+// EditPost replaces the content of a post, keeping the previous versions
+func (r *RevisionRepository) EditPost(postID, editorID, content, reason string) error {
+	return r.edit("posts", "post_id", postID, editorID, content, reason, config.ErrPostNotFound)
+}
+
+// EditComment replaces the content of a comment, keeping the previous versions
+func (r *RevisionRepository) EditComment(commentID, editorID, content, reason string) error {
+	return r.edit("comments", "comment_id", commentID, editorID, content, reason, config.ErrCommentNotFound)
+}
+
+// edit stores new content for a row of targetTable identified by
+// targetColumn and records it as a revision. Content that was never edited
+// has no revisions yet, so the original is stored as revision 1 first.
+// Saving unchanged content does nothing.
+func (r *RevisionRepository) edit(targetTable, targetColumn, targetID, editorID, content, reason string, errNotFound error) error {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var authorID, current string
+	var createdAt time.Time
+	err = tx.QueryRow(
+		fmt.Sprintf("SELECT t.user_id, t.content, t.created_at FROM %s t WHERE t.%s = ? AND %s", targetTable, targetColumn, liveClause(targetTable)),
+		targetID,
+	).Scan(&authorID, &current, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errNotFound
+		}
+		return err
+	}
+
+	if content == current {
+		return nil
+	}
+
+	var latest int
+	err = tx.QueryRow(
+		fmt.Sprintf("SELECT COALESCE(MAX(revision_number), 0) FROM content_revisions WHERE %s = ?", targetColumn),
+		targetID,
+	).Scan(&latest)
+	if err != nil {
+		return err
+	}
+
+	insert := fmt.Sprintf(
+		"INSERT INTO content_revisions (revision_id, %s, revision_number, content, editor_id, reason, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		targetColumn,
+	)
+
+	if latest == 0 {
+		_, err = tx.Exec(insert, utils.GenerateUUID(), targetID, 1, current, authorID, "", createdAt)
+		if err != nil {
+			return err
+		}
+		latest = 1
+	}
+
+	editedAt := time.Now()
+	_, err = tx.Exec(insert, utils.GenerateUUID(), targetID, latest+1, content, editorID, reason, editedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("UPDATE %s SET content = ?, updated_at = ? WHERE %s = ?", targetTable, targetColumn),
+		content, editedAt, targetID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// ListForPost returns every revision of a post, oldest first
+func (r *RevisionRepository) ListForPost(postID string) ([]models.Revision, error) {
+	return r.list("posts", "post_id", postID, config.ErrPostNotFound)
+}
+
+// ListForComment returns every revision of a comment, oldest first
+func (r *RevisionRepository) ListForComment(commentID string) ([]models.Revision, error) {
+	return r.list("comments", "comment_id", commentID, config.ErrCommentNotFound)
+}
+
+// list returns the revisions of a row of targetTable. Content that was never
+// edited has a single revision: itself. Deleted content and drafts have no
+// visible history.
+func (r *RevisionRepository) list(targetTable, targetColumn, targetID string, errNotFound error) ([]models.Revision, error) {
+	var original models.Revision
+	err := r.DB.QueryRow(
+		fmt.Sprintf(`SELECT t.content, t.user_id, u.username, t.created_at
+		FROM %s t JOIN user u ON u.user_id = t.user_id
+		WHERE t.%s = ? AND %s`, targetTable, targetColumn, liveClause(targetTable)),
+		targetID,
+	).Scan(&original.Content, &original.EditorID, &original.Editor, &original.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(
+		fmt.Sprintf(`SELECT rev.revision_number, rev.content, rev.editor_id, u.username, rev.reason, rev.created_at
+		FROM content_revisions rev JOIN user u ON u.user_id = rev.editor_id
+		WHERE rev.%s = ?
+		ORDER BY rev.revision_number`, targetColumn),
+		targetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		var revision models.Revision
+		err := rows.Scan(&revision.Number, &revision.Content, &revision.EditorID, &revision.Editor, &revision.Reason, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		original.Number = 1
+		revisions = append(revisions, original)
+	}
+
+	return revisions, nil
+}
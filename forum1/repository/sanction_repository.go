@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// SanctionRepository handles warnings, suspensions and bans
+type SanctionRepository struct {
+	DB *sql.DB
+}
+
+// NewSanctionRepository creates a new SanctionRepository
+func NewSanctionRepository(db *sql.DB) *SanctionRepository {
+	return &SanctionRepository{DB: db}
+}
+
+// Create records a new sanction. Suspensions must carry an expiry time.
+func (r *SanctionRepository) Create(userID, moderatorID, sanctionType, reason string, expiresAt *time.Time) (*models.Sanction, error) {
+	sanction := &models.Sanction{
+		ID:           utils.GenerateUUID(),
+		UserID:       userID,
+		ModeratorID:  moderatorID,
+		SanctionType: sanctionType,
+		Reason:       reason,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    expiresAt,
+	}
+
+	_, err := r.DB.Exec(
+		"INSERT INTO user_sanctions (sanction_id, user_id, moderator_id, sanction_type, reason, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		sanction.ID, sanction.UserID, sanction.ModeratorID, sanction.SanctionType, sanction.Reason, sanction.CreatedAt, sanction.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return sanction, nil
+}
+
+// GetByID retrieves a sanction by its ID
+func (r *SanctionRepository) GetByID(sanctionID string) (*models.Sanction, error) {
+	var sanction models.Sanction
+
+	err := r.DB.QueryRow(
+		"SELECT sanction_id, user_id, moderator_id, sanction_type, reason, created_at, expires_at, revoked_at, revoked_by FROM user_sanctions WHERE sanction_id = ?",
+		sanctionID,
+	).Scan(&sanction.ID, &sanction.UserID, &sanction.ModeratorID, &sanction.SanctionType, &sanction.Reason,
+		&sanction.CreatedAt, &sanction.ExpiresAt, &sanction.RevokedAt, &sanction.RevokedBy)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrSanctionNotFound
+		}
+		return nil, err
+	}
+
+	return &sanction, nil
+}
+
+// ListByUserID returns the full sanction history of a user, newest first
+func (r *SanctionRepository) ListByUserID(userID string) ([]models.Sanction, error) {
+	rows, err := r.DB.Query(
+		"SELECT sanction_id, user_id, moderator_id, sanction_type, reason, created_at, expires_at, revoked_at, revoked_by FROM user_sanctions WHERE user_id = ? ORDER BY created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sanctions := []models.Sanction{}
+	for rows.Next() {
+		var sanction models.Sanction
+		err := rows.Scan(&sanction.ID, &sanction.UserID, &sanction.ModeratorID, &sanction.SanctionType, &sanction.Reason,
+			&sanction.CreatedAt, &sanction.ExpiresAt, &sanction.RevokedAt, &sanction.RevokedBy)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, sanction)
+	}
+
+	return sanctions, rows.Err()
+}
+
+// GetActiveRestriction returns the ban or running suspension that currently
+// blocks a user, or nil if they may use the forum. Bans take precedence.
+func (r *SanctionRepository) GetActiveRestriction(userID string) (*models.Sanction, error) {
+	var sanction models.Sanction
+
+	err := r.DB.QueryRow(
+		`SELECT sanction_id, user_id, moderator_id, sanction_type, reason, created_at, expires_at, revoked_at, revoked_by
+		FROM user_sanctions
+		WHERE user_id = ? AND revoked_at IS NULL
+			AND (sanction_type = 'ban' OR (sanction_type = 'suspension' AND expires_at > ?))
+		ORDER BY sanction_type = 'ban' DESC, expires_at DESC
+		LIMIT 1`,
+		userID, time.Now(),
+	).Scan(&sanction.ID, &sanction.UserID, &sanction.ModeratorID, &sanction.SanctionType, &sanction.Reason,
+		&sanction.CreatedAt, &sanction.ExpiresAt, &sanction.RevokedAt, &sanction.RevokedBy)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &sanction, nil
+}
+
+// CountActiveWarnings counts the unrevoked warnings issued since a point in time
+func (r *SanctionRepository) CountActiveWarnings(userID string, since time.Time) (int, error) {
+	var count int
+	err := r.DB.QueryRow(
+		"SELECT COUNT(*) FROM user_sanctions WHERE user_id = ? AND sanction_type = 'warning' AND revoked_at IS NULL AND created_at > ?",
+		userID, since,
+	).Scan(&count)
+	return count, err
+}
+
+// Revoke lifts a sanction early
+func (r *SanctionRepository) Revoke(sanctionID, moderatorID string) error {
+	result, err := r.DB.Exec(
+		"UPDATE user_sanctions SET revoked_at = ?, revoked_by = ? WHERE sanction_id = ? AND revoked_at IS NULL",
+		time.Now(), moderatorID, sanctionID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrSanctionNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// searchSelect matches posts and comments through their FTS5 tables. The
+// snippet markers are replaced with <mark> tags after HTML escaping.
+const searchSelect = `SELECT kind, post_id, comment_id, user_id, username, category_id, snippet, rank, created_at FROM (
+	SELECT 'post' AS kind, p.post_id, '' AS comment_id, p.user_id, u.username, u.username_canonical, p.category_id,
+		snippet(posts_fts, 0, char(2), char(3), '…', 16) AS snippet, bm25(posts_fts) AS rank, p.created_at
+	FROM posts_fts
+	JOIN posts p ON p.rowid = posts_fts.rowid
+	JOIN user u ON u.user_id = p.user_id
+	WHERE posts_fts MATCH ? AND p.deleted_at IS NULL AND p.draft = 0
+	UNION ALL
+	SELECT 'comment', c.post_id, c.comment_id, c.user_id, u.username, u.username_canonical, p.category_id,
+		snippet(comments_fts, 0, char(2), char(3), '…', 16), bm25(comments_fts), c.created_at
+	FROM comments_fts
+	JOIN comments c ON c.rowid = comments_fts.rowid
+	JOIN posts p ON p.post_id = c.post_id
+	JOIN user u ON u.user_id = c.user_id
+	WHERE comments_fts MATCH ? AND c.deleted_at IS NULL
+)`
+
+// SearchRepository handles full-text search over posts and comments
+type SearchRepository struct {
+	DB *sql.DB
+}
+
+// NewSearchRepository creates a new SearchRepository
+func NewSearchRepository(db *sql.DB) *SearchRepository {
+	return &SearchRepository{DB: db}
+}
+
+// Search returns a page of posts and comments matching a query, most
+// relevant first. Content by authors the viewer muted or blocked is left out.
+func (r *SearchRepository) Search(query models.SearchQuery, viewerID string) ([]models.SearchResult, error) {
+	author := ""
+	if query.Author != "" {
+		author = utils.CanonicalUsername(query.Author)
+	}
+
+	rows, err := r.DB.Query(
+		searchSelect+`
+		WHERE (? = '' OR kind = ?)
+			AND (? = '' OR category_id = ?)
+			AND (? = '' OR username_canonical = ?)
+			AND (? IS NULL OR created_at >= ?)
+			AND (? IS NULL OR created_at < ?)
+			AND user_id `+hiddenAuthorsClause+`
+		ORDER BY rank, created_at DESC
+		LIMIT ? OFFSET ?`,
+		query.Match, query.Match,
+		query.Type, query.Type,
+		query.CategoryID, query.CategoryID,
+		author, author,
+		query.From, query.From,
+		query.To, query.To,
+		viewerID, viewerID,
+		query.Limit, query.Offset,
+	)
+	if err != nil {
+		return nil, searchError(err)
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var result models.SearchResult
+		err := rows.Scan(&result.Type, &result.PostID, &result.CommentID, &result.AuthorID, &result.Author,
+			&result.CategoryID, &result.Snippet, &result.Rank, &result.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result.Snippet = utils.HighlightSnippet(result.Snippet)
+		results = append(results, result)
+	}
+
+	return results, searchError(rows.Err())
+}
+
+// searchError reports a missing search index (SQLite built without FTS5) as ErrSearchUnavailable
+func searchError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "no such table") {
+		return config.ErrSearchUnavailable
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/models"
+	"forum/utils"
+)
+
+// SecurityEventRepository handles the per-user security log and known devices
+type SecurityEventRepository struct {
+	DB *sql.DB
+}
+
+// NewSecurityEventRepository creates a new SecurityEventRepository
+func NewSecurityEventRepository(db *sql.DB) *SecurityEventRepository {
+	return &SecurityEventRepository{DB: db}
+}
+
+// Record adds an event to a user's security log
+func (r *SecurityEventRepository) Record(userID, eventType, ipAddress, userAgent, details string) error {
+	_, err := r.DB.Exec(
+		"INSERT INTO security_events (event_id, user_id, event_type, ip_address, user_agent, details, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		utils.GenerateUUID(), userID, eventType, ipAddress, userAgent, details, time.Now(),
+	)
+	return err
+}
+
+// RecordByEmail adds an event to the log of the account owning an email.
+// Nothing is recorded for unknown emails; the single statement costs the
+// same either way.
+func (r *SecurityEventRepository) RecordByEmail(email, eventType, ipAddress, userAgent, details string) error {
+	_, err := r.DB.Exec(
+		`INSERT INTO security_events (event_id, user_id, event_type, ip_address, user_agent, details, created_at)
+		SELECT ?, user_id, ?, ?, ?, ?, ? FROM user WHERE email_canonical = ?`,
+		utils.GenerateUUID(), eventType, ipAddress, userAgent, details, time.Now(), utils.CanonicalEmail(email),
+	)
+	return err
+}
+
+// ListByUserID returns a page of a user's security events, newest first
+func (r *SecurityEventRepository) ListByUserID(userID string, limit, offset int) ([]models.SecurityEvent, error) {
+	rows, err := r.DB.Query(
+		`SELECT event_id, user_id, event_type, ip_address, user_agent, details, created_at
+		FROM security_events WHERE user_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		var event models.SecurityEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.EventType, &event.IPAddress, &event.UserAgent, &event.Details, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// RememberDevice marks a device fingerprint as seen for a user. It reports
+// whether the device is new, and whether it is the first device the user
+// ever logged in from (a first login is not worth an alert).
+func (r *SecurityEventRepository) RememberDevice(userID, fingerprint, userAgent string) (isNew, isFirst bool, err error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, false, err
+	}
+	defer tx.Rollback()
+
+	var known int
+	err = tx.QueryRow("SELECT COUNT(*) FROM user_devices WHERE user_id = ?", userID).Scan(&known)
+	if err != nil {
+		return false, false, err
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(
+		"UPDATE user_devices SET last_seen_at = ? WHERE user_id = ? AND fingerprint = ?",
+		now, userID, fingerprint,
+	)
+	if err != nil {
+		return false, false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, false, err
+	}
+	if rows == 0 {
+		_, err = tx.Exec(
+			"INSERT INTO user_devices (user_id, fingerprint, user_agent, first_seen_at, last_seen_at) VALUES (?, ?, ?, ?, ?)",
+			userID, fingerprint, userAgent, now, now,
+		)
+		if err != nil {
+			return false, false, err
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return false, false, err
+	}
+
+	return rows == 0, known == 0, nil
+}
@@ -19,19 +19,24 @@ func NewSessionRepository(db *sql.DB) *SessionRepository {
 	return &SessionRepository{DB: db}
 }
 
-// Create creates a new session for a user
-func (r *SessionRepository) Create(userID, ipAddress string) (*models.Session, error) {
+// Create creates a new session for a user, replacing their existing ones.
+// It also returns how many sessions were replaced.
+func (r *SessionRepository) Create(userID, ipAddress string) (*models.Session, int64, error) {
 	// Start a transaction
 	tx, err := r.DB.Begin()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer tx.Rollback()
 
 	// First, delete any existing sessions for this user
-	_, err = tx.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	result, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	replaced, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// Generate a new session ID
@@ -45,12 +50,12 @@ func (r *SessionRepository) Create(userID, ipAddress string) (*models.Session, e
 		userID, sessionID, ipAddress, now, expiresAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Return the session
@@ -62,7 +67,7 @@ func (r *SessionRepository) Create(userID, ipAddress string) (*models.Session, e
 		ExpiresAt: expiresAt,
 	}
 
-	return session, nil
+	return session, replaced, nil
 }
 
 // GetBySessionID retrieves a session by its ID
@@ -97,3 +102,147 @@ func (r *SessionRepository) Delete(sessionID string) error {
 	_, err := r.DB.Exec("DELETE FROM sessions WHERE session_id = ?", sessionID)
 	return err
 }
+
+// DeleteByUserID removes every session of a user and returns how many there were
+func (r *SessionRepository) DeleteByUserID(userID string) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteOthers removes every session of a user but the given one and returns
+// how many there were
+func (r *SessionRepository) DeleteOthers(userID, keepSessionID string) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM sessions WHERE user_id = ? AND session_id != ?", userID, keepSessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// impersonationSelect selects impersonation sessions with both usernames
+const impersonationSelect = `SELECT i.impersonation_id, i.session_id, i.admin_id, a.username, i.target_user_id, t.username,
+	i.reason, i.ip_address, i.created_at, i.expires_at, i.ended_at
+	FROM impersonation_sessions i
+	JOIN user a ON a.user_id = i.admin_id
+	JOIN user t ON t.user_id = i.target_user_id`
+
+// CreateImpersonation starts an impersonation session for an admin. Any
+// impersonation the admin still has running is ended first.
+func (r *SessionRepository) CreateImpersonation(adminID, targetUserID, reason, ipAddress string, ttl time.Duration) (*models.ImpersonationSession, error) {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(
+		"UPDATE impersonation_sessions SET ended_at = ? WHERE admin_id = ? AND ended_at IS NULL",
+		now, adminID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	impersonationID := utils.GenerateUUID()
+	_, err = tx.Exec(
+		`INSERT INTO impersonation_sessions (impersonation_id, session_id, admin_id, target_user_id, reason, ip_address, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		impersonationID, utils.GenerateSessionToken(), adminID, targetUserID, reason, ipAddress, now, now.Add(ttl),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read it back with the usernames filled in
+	session, err := scanImpersonation(tx.QueryRow(impersonationSelect+" WHERE i.impersonation_id = ?", impersonationID))
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetImpersonation returns a running impersonation session by its token
+func (r *SessionRepository) GetImpersonation(sessionID string) (*models.ImpersonationSession, error) {
+	session, err := scanImpersonation(r.DB.QueryRow(impersonationSelect+" WHERE i.session_id = ?", sessionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	if session.EndedAt != nil {
+		return nil, config.ErrSessionNotFound
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, config.ErrSessionExpired
+	}
+
+	return session, nil
+}
+
+// EndImpersonation stops a running impersonation session
+func (r *SessionRepository) EndImpersonation(sessionID string) error {
+	result, err := r.DB.Exec(
+		"UPDATE impersonation_sessions SET ended_at = ? WHERE session_id = ? AND ended_at IS NULL",
+		time.Now(), sessionID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// ListImpersonations returns a page of the impersonation audit log, newest first
+func (r *SessionRepository) ListImpersonations(limit, offset int) ([]models.ImpersonationSession, error) {
+	rows, err := r.DB.Query(impersonationSelect+" ORDER BY i.created_at DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.ImpersonationSession{}
+	for rows.Next() {
+		session, err := scanImpersonation(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// scanImpersonation reads a row selected with impersonationSelect
+func scanImpersonation(row scanner) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	err := row.Scan(
+		&session.ID, &session.SessionID, &session.AdminID, &session.AdminUsername,
+		&session.TargetUserID, &session.TargetUsername, &session.Reason, &session.IPAddress,
+		&session.CreatedAt, &session.ExpiresAt, &session.EndedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/config"
+	"forum/models"
+)
+
+// trashSelect selects deleted posts and comments with their authors and
+// the user who deleted them
+const trashSelect = `SELECT kind, id, post_id, user_id, username, content, created_at, deleted_at, deleted_by, deleted_by_username, delete_reason FROM (
+	SELECT 'post' AS kind, p.post_id AS id, p.post_id, p.user_id, u.username, p.content, p.created_at,
+		p.deleted_at, p.deleted_by, d.username AS deleted_by_username, p.delete_reason
+	FROM posts p
+	JOIN user u ON u.user_id = p.user_id
+	LEFT JOIN user d ON d.user_id = p.deleted_by
+	WHERE p.deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'comment', c.comment_id, c.post_id, c.user_id, u.username, c.content, c.created_at,
+		c.deleted_at, c.deleted_by, d.username, c.delete_reason
+	FROM comments c
+	JOIN user u ON u.user_id = c.user_id
+	LEFT JOIN user d ON d.user_id = c.deleted_by
+	WHERE c.deleted_at IS NOT NULL
+)`
+
+// TrashRepository handles soft deletion of posts and comments
+type TrashRepository struct {
+	DB *sql.DB
+}
+
+// NewTrashRepository creates a new TrashRepository
+func NewTrashRepository(db *sql.DB) *TrashRepository {
+	return &TrashRepository{DB: db}
+}
+
+// DeletePost moves a post to the trash. Its comments stay visible.
+func (r *TrashRepository) DeletePost(postID, deletedBy, reason string) error {
+	return r.setDeleted("posts", "post_id", postID, deletedBy, reason, config.ErrPostNotFound)
+}
+
+// DeleteComment moves a comment to the trash
+func (r *TrashRepository) DeleteComment(commentID, deletedBy, reason string) error {
+	return r.setDeleted("comments", "comment_id", commentID, deletedBy, reason, config.ErrCommentNotFound)
+}
+
+// RestorePost takes a post out of the trash
+func (r *TrashRepository) RestorePost(postID string, retention time.Duration) error {
+	return r.restore("posts", "post_id", postID, retention, config.ErrPostNotFound)
+}
+
+// RestoreComment takes a comment out of the trash
+func (r *TrashRepository) RestoreComment(commentID string, retention time.Duration) error {
+	return r.restore("comments", "comment_id", commentID, retention, config.ErrCommentNotFound)
+}
+
+// setDeleted marks a row of targetTable as deleted, if it is not already
+func (r *TrashRepository) setDeleted(targetTable, targetColumn, targetID, deletedBy, reason string, errNotFound error) error {
+	result, err := r.DB.Exec(
+		fmt.Sprintf("UPDATE %s AS t SET deleted_at = ?, deleted_by = ?, delete_reason = ? WHERE t.%s = ? AND %s", targetTable, targetColumn, liveClause(targetTable)),
+		time.Now(), deletedBy, reason, targetID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// restore clears the deletion of a row of targetTable that is still within
+// the retention period
+func (r *TrashRepository) restore(targetTable, targetColumn, targetID string, retention time.Duration, errNotFound error) error {
+	result, err := r.DB.Exec(
+		fmt.Sprintf(`UPDATE %s SET deleted_at = NULL, deleted_by = NULL, delete_reason = ''
+		WHERE %s = ? AND deleted_at IS NOT NULL AND deleted_at > ?`, targetTable, targetColumn),
+		targetID, time.Now().Add(-retention),
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errNotFound
+	}
+
+	return nil
+}
+
+// List returns a page of the trash, most recently deleted first. kind is
+// "post", "comment" or "" for both.
+func (r *TrashRepository) List(kind string, retention time.Duration, limit, offset int) ([]models.TrashItem, error) {
+	rows, err := r.DB.Query(
+		trashSelect+`
+		WHERE (? = '' OR kind = ?) AND deleted_at > ?
+		ORDER BY deleted_at DESC
+		LIMIT ? OFFSET ?`,
+		kind, kind, time.Now().Add(-retention), limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		err := rows.Scan(&item.Type, &item.ID, &item.PostID, &item.AuthorID, &item.Author, &item.Content, &item.CreatedAt,
+			&item.DeletedAt, &item.DeletedByID, &item.DeletedBy, &item.Reason)
+		if err != nil {
+			return nil, err
+		}
+		item.PurgeAt = item.DeletedAt.Add(retention)
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// Purge hard deletes content that has been in the trash longer than
+// retention. A deleted post that still has live comments keeps its row so
+// the comments stay readable, but its content, history, attachments,
+// reactions, mentions, bookmarks and the notifications about it are erased.
+func (r *TrashRepository) Purge(retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	statements := []string{
+		"DELETE FROM comments WHERE deleted_at IS NOT NULL AND deleted_at <= ?",
+		`DELETE FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= ?
+			AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.post_id = posts.post_id)`,
+	}
+	for _, stmt := range statements {
+		result, err := tx.Exec(stmt, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += rows
+	}
+
+	// Posts kept for their comments
+	erase := []string{
+		"DELETE FROM content_revisions WHERE post_id IN (SELECT post_id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= ?)",
+		"DELETE FROM attachments WHERE post_id IN (SELECT post_id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= ?)",
+		"DELETE FROM reactions WHERE post_id IN (SELECT post_id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= ?)",
+		"DELETE FROM mentions WHERE post_id IN (SELECT post_id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= ?)",
+		"DELETE FROM notifications WHERE comment_id IS NULL AND post_id IN (SELECT post_id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= ?)",
+		"DELETE FROM bookmarks WHERE post_id IN (SELECT post_id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= ?)",
+		"UPDATE posts SET content = '', delete_reason = '' WHERE deleted_at IS NOT NULL AND deleted_at <= ? AND content != ''",
+	}
+	for _, stmt := range erase {
+		if _, err := tx.Exec(stmt, cutoff); err != nil {
+			return 0, err
+		}
+	}
+
+	// Commit the transaction
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
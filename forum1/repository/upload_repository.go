@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+// uploadSelect selects the columns scanned by scanUpload
+const uploadSelect = `SELECT up.upload_id, up.sha256, up.content_type, up.size, up.width, up.height,
+	up.path, up.thumbnail_path, up.thumbnail_type, up.created_at
+FROM uploads up`
+
+// UploadRepository handles uploaded images and their attachment to posts and comments
+type UploadRepository struct {
+	DB *sql.DB
+}
+
+// NewUploadRepository creates a new UploadRepository
+func NewUploadRepository(db *sql.DB) *UploadRepository {
+	return &UploadRepository{DB: db}
+}
+
+// Create stores a new upload for a user. If the same file was stored in the
+// meantime, the existing upload is returned instead.
+func (r *UploadRepository) Create(userID string, upload models.Upload) (*models.Upload, error) {
+	_, err := r.DB.Exec(
+		`INSERT INTO uploads (upload_id, sha256, content_type, size, width, height, path, thumbnail_path, thumbnail_type, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (sha256) DO NOTHING`,
+		utils.GenerateUUID(), upload.SHA256, upload.ContentType, upload.Size, upload.Width, upload.Height,
+		upload.Path, upload.ThumbnailPath, upload.ThumbnailType, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := r.GetBySHA256(upload.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.AddUploader(userID, stored.ID); err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// AddUploader records that a user uploaded a file, ignoring repeats
+func (r *UploadRepository) AddUploader(userID, uploadID string) error {
+	_, err := r.DB.Exec(
+		"INSERT OR IGNORE INTO user_uploads (user_id, upload_id, created_at) VALUES (?, ?, ?)",
+		userID, uploadID, time.Now(),
+	)
+	return err
+}
+
+// GetByID retrieves an upload by its ID
+func (r *UploadRepository) GetByID(uploadID string) (*models.Upload, error) {
+	return r.get(uploadSelect+" WHERE up.upload_id = ?", uploadID)
+}
+
+// GetBySHA256 retrieves the upload of a file by its content hash
+func (r *UploadRepository) GetBySHA256(sha256 string) (*models.Upload, error) {
+	return r.get(uploadSelect+" WHERE up.sha256 = ?", sha256)
+}
+
+// get retrieves a single upload
+func (r *UploadRepository) get(query string, args ...any) (*models.Upload, error) {
+	upload, err := scanUpload(r.DB.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// CheckOwned checks that the user uploaded every one of uploadIDs
+func (r *UploadRepository) CheckOwned(userID string, uploadIDs []string) error {
+	for _, uploadID := range uploadIDs {
+		var owned bool
+		err := r.DB.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM user_uploads WHERE user_id = ? AND upload_id = ?)",
+			userID, uploadID,
+		).Scan(&owned)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return config.ErrInvalidAttachment
+		}
+	}
+
+	return nil
+}
+
+// ReplacePostAttachments swaps the attachments of a post for the given uploads
+func (r *UploadRepository) ReplacePostAttachments(postID string, uploadIDs []string) error {
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM attachments WHERE post_id = ?", postID); err != nil {
+		return err
+	}
+	if err = insertAttachments(tx, "post_id", postID, uploadIDs); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// insertAttachments attaches uploads to the post or comment in targetColumn,
+// in the order given, as part of the transaction that creates or edits it
+func insertAttachments(tx *sql.Tx, targetColumn, targetID string, uploadIDs []string) error {
+	createdAt := time.Now()
+	for position, uploadID := range uploadIDs {
+		_, err := tx.Exec(
+			"INSERT INTO attachments (attachment_id, upload_id, "+targetColumn+", position, created_at) VALUES (?, ?, ?, ?, ?)",
+			utils.GenerateUUID(), uploadID, targetID, position, createdAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListForPosts returns the attachments of each post, keyed by post ID
+func (r *UploadRepository) ListForPosts(postIDs []string) (map[string][]models.Upload, error) {
+	return r.listFor("post_id", postIDs)
+}
+
+// ListForComments returns the attachments of each comment, keyed by comment ID
+func (r *UploadRepository) ListForComments(commentIDs []string) (map[string][]models.Upload, error) {
+	return r.listFor("comment_id", commentIDs)
+}
+
+// listFor loads the attachments of several posts or comments in one query
+func (r *UploadRepository) listFor(targetColumn string, targetIDs []string) (map[string][]models.Upload, error) {
+	attachments := map[string][]models.Upload{}
+	if len(targetIDs) == 0 {
+		return attachments, nil
+	}
+
+	args := make([]any, len(targetIDs))
+	for i, id := range targetIDs {
+		args[i] = id
+	}
+
+	rows, err := r.DB.Query(
+		`SELECT a.`+targetColumn+`, up.upload_id, up.sha256, up.content_type, up.size, up.width, up.height,
+			up.path, up.thumbnail_path, up.thumbnail_type, up.created_at
+		FROM attachments a JOIN uploads up ON up.upload_id = a.upload_id
+		WHERE a.`+targetColumn+` IN (?`+strings.Repeat(", ?", len(targetIDs)-1)+`)
+		ORDER BY a.position`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID string
+		var upload models.Upload
+		err := rows.Scan(&targetID, &upload.ID, &upload.SHA256, &upload.ContentType, &upload.Size, &upload.Width, &upload.Height,
+			&upload.Path, &upload.ThumbnailPath, &upload.ThumbnailType, &upload.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		setUploadURLs(&upload)
+		attachments[targetID] = append(attachments[targetID], upload)
+	}
+
+	return attachments, rows.Err()
+}
+
+// scanUpload scans a row produced by uploadSelect
+func scanUpload(row scanner) (*models.Upload, error) {
+	var upload models.Upload
+	err := row.Scan(&upload.ID, &upload.SHA256, &upload.ContentType, &upload.Size, &upload.Width, &upload.Height,
+		&upload.Path, &upload.ThumbnailPath, &upload.ThumbnailType, &upload.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	setUploadURLs(&upload)
+	return &upload, nil
+}
+
+// setUploadURLs fills in the public URLs of an upload
+func setUploadURLs(upload *models.Upload) {
+	upload.URL = "/uploads/" + upload.ID
+	upload.ThumbnailURL = "/uploads/" + upload.ID + "/thumbnail"
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"time"
+
+	"forum/config"
+	"forum/models"
+)
+
+// ExportData collects everything stored about a user into one archive
+func (r *UserRepository) ExportData(userID string) (*models.UserDataExport, error) {
+	user, err := r.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDataExport{
+		ExportedAt: time.Now(),
+		Profile:    *user,
+		Posts:      []models.Post{},
+		Drafts:     []models.Draft{},
+		Comments:   []models.Comment{},
+		Reactions:  []models.Reaction{},
+		Messages:   []models.Message{},
+		Bookmarks:  []models.Bookmark{},
+		Sessions:   []models.ExportedSession{},
+	}
+
+	// Posts
+	rows, err := r.DB.Query(
+		"SELECT post_id, user_id, category_id, content, created_at, updated_at FROM posts WHERE user_id = ? AND draft = 0 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var post models.Post
+		if err := rows.Scan(&post.ID, &post.UserID, &post.CategoryID, &post.Content, &post.CreatedAt, &post.UpdatedAt); err != nil {
+			return nil, err
+		}
+		export.Posts = append(export.Posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Drafts
+	rows, err = r.DB.Query(
+		"SELECT post_id, user_id, category_id, content, created_at, updated_at, publish_at FROM posts WHERE user_id = ? AND draft = 1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var draft models.Draft
+		if err := rows.Scan(&draft.ID, &draft.UserID, &draft.CategoryID, &draft.Content, &draft.CreatedAt, &draft.UpdatedAt, &draft.PublishAt); err != nil {
+			return nil, err
+		}
+		export.Drafts = append(export.Drafts, draft)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Comments
+	rows, err = r.DB.Query(
+		"SELECT comment_id, post_id, user_id, content, created_at, updated_at FROM comments WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var comment models.Comment
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+			return nil, err
+		}
+		export.Comments = append(export.Comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reactions
+	rows, err = r.DB.Query(
+		"SELECT reaction_id, user_id, reaction_type, post_id, comment_id, created_at FROM reactions WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var reaction models.Reaction
+		if err := rows.Scan(&reaction.ID, &reaction.UserID, &reaction.ReactionType, &reaction.PostID, &reaction.CommentID, &reaction.CreatedAt); err != nil {
+			return nil, err
+		}
+		export.Reactions = append(export.Reactions, reaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Messages sent
+	rows, err = r.DB.Query(
+		"SELECT message_id, conversation_id, sender_id, content, created_at FROM messages WHERE sender_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var message models.Message
+		if err := rows.Scan(&message.ID, &message.ConversationID, &message.SenderID, &message.Content, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		export.Messages = append(export.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Bookmarks
+	rows, err = r.DB.Query(bookmarkSelect+" WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		bookmark, err := scanBookmark(rows)
+		if err != nil {
+			return nil, err
+		}
+		export.Bookmarks = append(export.Bookmarks, *bookmark)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Sessions
+	rows, err = r.DB.Query(
+		"SELECT ip_address, created_at, expires_at FROM sessions WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var session models.ExportedSession
+		if err := rows.Scan(&session.IPAddress, &session.CreatedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		export.Sessions = append(export.Sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Delete removes a user account. In erase mode all of the user's content is
+// deleted with it, except that posts other users have commented on are kept
+// as deleted placeholders, as the trash purge does, so their replies survive.
+// In anonymise mode published posts and comments are handed over to the
+// placeholder author so threads stay intact, as are messages so
+// conversations do, and drafts are erased.
+func (r *UserRepository) Delete(userID, mode string) error {
+	if mode != config.DELETION_MODE_ERASE && mode != config.DELETION_MODE_ANONYMISE {
+		return config.ErrInvalidDeletionMode
+	}
+	if userID == config.DELETED_USER_ID {
+		return config.ErrUserNotFound
+	}
+
+	// Start a transaction
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if mode == config.DELETION_MODE_ANONYMISE {
+		_, err = tx.Exec("UPDATE posts SET user_id = ? WHERE user_id = ? AND draft = 0", config.DELETED_USER_ID, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE comments SET user_id = ? WHERE user_id = ?", config.DELETED_USER_ID, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE messages SET sender_id = ? WHERE sender_id = ?", config.DELETED_USER_ID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if mode == config.DELETION_MODE_ERASE {
+		kept := `SELECT p.post_id FROM posts p WHERE p.user_id = ?
+			AND EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.post_id AND c.user_id != ?)`
+		erase := []string{
+			"DELETE FROM content_revisions WHERE post_id IN (" + kept + ")",
+			"DELETE FROM attachments WHERE post_id IN (" + kept + ")",
+			"DELETE FROM reactions WHERE post_id IN (" + kept + ")",
+			"DELETE FROM mentions WHERE post_id IN (" + kept + ")",
+			"DELETE FROM notifications WHERE post_id IN (" + kept + ") AND comment_id IS NULL",
+			"DELETE FROM bookmarks WHERE post_id IN (" + kept + ")",
+		}
+		for _, stmt := range erase {
+			if _, err = tx.Exec(stmt, userID, userID); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(
+			`UPDATE posts SET user_id = ?, content = '', delete_reason = '', deleted_at = COALESCE(deleted_at, ?)
+			WHERE post_id IN (`+kept+`)`,
+			config.DELETED_USER_ID, time.Now(), userID, userID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Sessions, credentials, reactions and any remaining content cascade
+	result, err := tx.Exec("DELETE FROM user WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrUserNotFound
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
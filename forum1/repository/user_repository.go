@@ -2,6 +2,8 @@ package repository
 
 import (
 	"database/sql"
+	"log"
+	"strings"
 	"time"
 
 	"forum/config"
@@ -19,11 +21,17 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
-// Create adds a new user to the database
-func (r *UserRepository) Create(reg models.UserRegistration) (*models.User, error) {
+// Create adds a new user to the database with the given status
+// (active, or pending when registrations need approval)
+func (r *UserRepository) Create(reg models.UserRegistration, status string) (*models.User, error) {
+	// Uniqueness is checked on the canonical forms so that case or Unicode
+	// variants of an existing username or email are rejected
+	usernameCanonical := utils.CanonicalUsername(reg.Username)
+	emailCanonical := utils.CanonicalEmail(reg.Email)
+
 	// Check if email is already taken
 	var count int
-	err := r.DB.QueryRow("SELECT COUNT(*) FROM user WHERE email = ?", reg.Email).Scan(&count)
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM user WHERE email_canonical = ?", emailCanonical).Scan(&count)
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +40,7 @@ func (r *UserRepository) Create(reg models.UserRegistration) (*models.User, erro
 	}
 
 	// Check if username is already taken
-	err = r.DB.QueryRow("SELECT COUNT(*) FROM user WHERE username = ?", reg.Username).Scan(&count)
+	err = r.DB.QueryRow("SELECT COUNT(*) FROM user WHERE username_canonical = ?", usernameCanonical).Scan(&count)
 	if err != nil {
 		return nil, err
 	}
@@ -55,10 +63,17 @@ func (r *UserRepository) Create(reg models.UserRegistration) (*models.User, erro
 
 	// Insert user record
 	_, err = tx.Exec(
-		"INSERT INTO user (user_id, username, email, created_at) VALUES (?, ?, ?, ?)",
-		userID, reg.Username, reg.Email, createdAt,
+		"INSERT INTO user (user_id, username, email, created_at, username_canonical, email_canonical, status) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		userID, reg.Username, reg.Email, createdAt, usernameCanonical, emailCanonical, status,
 	)
 	if err != nil {
+		// A concurrent registration may have claimed the name between the checks and the insert
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			if strings.Contains(err.Error(), "email") {
+				return nil, config.ErrEmailTaken
+			}
+			return nil, config.ErrUsernameTaken
+		}
 		return nil, err
 	}
 
@@ -87,6 +102,8 @@ func (r *UserRepository) Create(reg models.UserRegistration) (*models.User, erro
 		ID:        userID,
 		Username:  reg.Username,
 		Email:     reg.Email,
+		Role:      config.ROLE_USER,
+		Status:    status,
 		CreatedAt: createdAt,
 	}
 
@@ -98,9 +115,9 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	var user models.User
 
 	err := r.DB.QueryRow(
-		"SELECT user_id, username, email, created_at FROM user WHERE LOWER(email) = LOWER(?)",
-		email,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
+		"SELECT user_id, username, email, role, status, created_at FROM user WHERE email_canonical = ?",
+		utils.CanonicalEmail(email),
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Status, &user.CreatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -117,9 +134,28 @@ func (r *UserRepository) GetByID(id string) (*models.User, error) {
 	var user models.User
 
 	err := r.DB.QueryRow(
-		"SELECT user_id, username, email, created_at FROM user WHERE user_id = ?",
+		"SELECT user_id, username, email, role, status, created_at FROM user WHERE user_id = ?",
 		id,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Status, &user.CreatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, config.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetByUsername retrieves a user by username, ignoring case
+func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
+	var user models.User
+
+	err := r.DB.QueryRow(
+		"SELECT user_id, username, email, role, status, created_at FROM user WHERE username_canonical = ?",
+		utils.CanonicalUsername(username),
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Status, &user.CreatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -131,6 +167,155 @@ func (r *UserRepository) GetByID(id string) (*models.User, error) {
 	return &user, nil
 }
 
+// SetRole changes the role of a user
+func (r *UserRepository) SetRole(userID, role string) error {
+	result, err := r.DB.Exec("UPDATE user SET role = ? WHERE user_id = ?", role, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetRoleByEmail changes the role of the user with the given email
+func (r *UserRepository) SetRoleByEmail(email, role string) error {
+	result, err := r.DB.Exec(
+		"UPDATE user SET role = ? WHERE email_canonical = ?",
+		role, utils.CanonicalEmail(email),
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateEmail changes a user's email after checking nobody else uses it
+func (r *UserRepository) UpdateEmail(userID, email string) error {
+	emailCanonical := utils.CanonicalEmail(email)
+
+	var count int
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM user WHERE email_canonical = ? AND user_id != ?", emailCanonical, userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return config.ErrEmailTaken
+	}
+
+	_, err = r.DB.Exec(
+		"UPDATE user SET email = ?, email_canonical = ? WHERE user_id = ?",
+		email, emailCanonical, userID,
+	)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return config.ErrEmailTaken
+	}
+
+	return err
+}
+
+// ListPending returns the accounts waiting for approval, oldest first
+func (r *UserRepository) ListPending() ([]models.User, error) {
+	rows, err := r.DB.Query(
+		"SELECT user_id, username, email, role, status, created_at FROM user WHERE status = ? ORDER BY created_at",
+		config.USER_STATUS_PENDING,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Status, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// SuggestUsernames returns up to limit active users whose username starts
+// with prefix, an exact match first, for completing @mentions. Users who
+// blocked the viewer or were blocked by them, and reserved usernames that
+// cannot be mentioned, are left out.
+func (r *UserRepository) SuggestUsernames(viewerID, prefix string, limit int) ([]models.UsernameSuggestion, error) {
+	// Canonical usernames only hold characters below 0x7f, so the range
+	// covers every username with the prefix and can use the index
+	canonical := utils.CanonicalUsername(prefix)
+	rows, err := r.DB.Query(
+		`SELECT u.username, COALESCE(p.display_name, '') FROM user u
+		LEFT JOIN user_profile p ON p.user_id = u.user_id
+		WHERE u.username_canonical >= ? AND u.username_canonical < ?
+			AND u.user_id NOT IN (?, ?) AND u.status = ?
+			AND u.user_id NOT IN (
+				SELECT blocker_id FROM user_blocks WHERE blocked_id = ?
+				UNION SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)
+		ORDER BY u.username_canonical = ? DESC, u.username_canonical
+		LIMIT ?`,
+		canonical, canonical+"\x7f",
+		viewerID, config.DELETED_USER_ID, config.USER_STATUS_ACTIVE,
+		viewerID, viewerID,
+		canonical, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.UsernameSuggestion{}
+	for rows.Next() {
+		var suggestion models.UsernameSuggestion
+		if err := rows.Scan(&suggestion.Username, &suggestion.DisplayName); err != nil {
+			return nil, err
+		}
+		if !utils.IsReservedUsername(suggestion.Username) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	return suggestions, rows.Err()
+}
+
+// Approve activates a pending account
+func (r *UserRepository) Approve(userID string) error {
+	result, err := r.DB.Exec(
+		"UPDATE user SET status = ? WHERE user_id = ? AND status = ?",
+		config.USER_STATUS_ACTIVE, userID, config.USER_STATUS_PENDING,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrUserNotFound
+	}
+
+	return nil
+}
+
 // GetAuthByUserID retrieves user authentication data by user ID
 func (r *UserRepository) GetAuthByUserID(userID string) (*models.UserAuth, error) {
 	var auth models.UserAuth
@@ -152,15 +337,24 @@ func (r *UserRepository) GetAuthByUserID(userID string) (*models.UserAuth, error
 
 // Authenticate validates a user's login credentials
 func (r *UserRepository) Authenticate(login models.UserLogin) (*models.User, error) {
-	// Get the user by email
+	// Get the user by email. Unknown accounts still pay for a hash
+	// comparison so response times do not reveal which emails exist.
 	user, err := r.GetByEmail(login.Email)
 	if err != nil {
-		return nil, config.ErrInvalidCredentials
+		if err == config.ErrUserNotFound {
+			utils.DummyPasswordCheck(login.Password)
+			return nil, config.ErrInvalidCredentials
+		}
+		return nil, err
 	}
 
 	// Get the user's authentication data
 	auth, err := r.GetAuthByUserID(user.ID)
 	if err != nil {
+		if err == config.ErrUserNotFound {
+			utils.DummyPasswordCheck(login.Password)
+			return nil, config.ErrInvalidCredentials
+		}
 		return nil, err
 	}
 
@@ -169,5 +363,37 @@ func (r *UserRepository) Authenticate(login models.UserLogin) (*models.User, err
 		return nil, config.ErrInvalidCredentials
 	}
 
+	// Upgrade legacy or outdated hashes now that we know the plain password
+	if utils.NeedsRehash(auth.PasswordHash) {
+		newHash, err := utils.HashPassword(login.Password)
+		if err == nil {
+			err = r.UpdatePasswordHash(user.ID, newHash)
+		}
+		if err != nil {
+			log.Printf("Failed to rehash password for user %s: %v", user.ID, err)
+		}
+	}
+
 	return user, nil
 }
+
+// UpdatePasswordHash replaces the stored password hash of a user
+func (r *UserRepository) UpdatePasswordHash(userID, passwordHash string) error {
+	result, err := r.DB.Exec(
+		"UPDATE user_auth SET password_hash = ? WHERE user_id = ?",
+		passwordHash, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return config.ErrUserNotFound
+	}
+
+	return nil
+}
@@ -4,22 +4,64 @@ import (
 	"database/sql"
 	"net/http"
 
+	"forum/config"
+	"forum/events"
 	"forum/handlers"
 	"forum/middleware"
 	"forum/repository"
+	"forum/storage"
 )
 
 // SetupRoutes configures all routes for the application
-func SetupRoutes(db *sql.DB) http.Handler {
+func SetupRoutes(db *sql.DB, blobStore storage.BlobStore, uploadConfig config.UploadConfig, eventHub *events.Hub) http.Handler {
 	// Create repositories
 	userRepo := repository.NewUserRepository(db)
 	sessionRepo := repository.NewSessionRepository(db)
+	profileRepo := repository.NewProfileRepository(db)
+	sanctionRepo := repository.NewSanctionRepository(db)
+	relationshipRepo := repository.NewRelationshipRepository(db)
+	postRepo := repository.NewPostRepository(db)
+	commentRepo := repository.NewCommentRepository(db)
+	reactionRepo := repository.NewReactionRepository(db)
+	inviteRepo := repository.NewInviteRepository(db)
+	domainRepo := repository.NewEmailDomainRepository(db)
+	securityRepo := repository.NewSecurityEventRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+	revisionRepo := repository.NewRevisionRepository(db)
+	trashRepo := repository.NewTrashRepository(db)
+	draftRepo := repository.NewDraftRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	mentionRepo := repository.NewMentionRepository(db)
+	conversationRepo := repository.NewConversationRepository(db)
+	bookmarkRepo := repository.NewBookmarkRepository(db)
+
+	// Load configuration
+	registrationConfig := config.LoadRegistrationConfig()
+	emailDomainConfig := config.LoadEmailDomainConfig()
+	impersonationConfig := config.LoadImpersonationConfig()
+	eventConfig := config.LoadEventConfig()
+
+	// Track who is viewing each live thread
+	presence := events.NewPresence(eventHub)
 
 	// Create services
-	authService := handlers.NewAuthService(userRepo, sessionRepo)
+	authService := handlers.NewAuthService(userRepo, sessionRepo, sanctionRepo, inviteRepo, domainRepo, securityRepo, registrationConfig, config.LoadNewDeviceAlerts())
+	userService := handlers.NewUserService(userRepo, sessionRepo, profileRepo, domainRepo, securityRepo)
+	relationshipService := handlers.NewRelationshipService(userRepo, relationshipRepo)
+	postService := handlers.NewPostService(postRepo, commentRepo, reactionRepo, uploadRepo, revisionRepo, trashRepo, draftRepo, mentionRepo, bookmarkRepo, notificationRepo, eventHub)
+	moderationService := handlers.NewModerationService(userRepo, sessionRepo, sanctionRepo, securityRepo, trashRepo, notificationRepo, eventHub, config.LoadModerationConfig())
+	inviteService := handlers.NewInviteService(inviteRepo, registrationConfig)
+	adminService := handlers.NewAdminService(userRepo, domainRepo, securityRepo, emailDomainConfig)
+	searchService := handlers.NewSearchService(searchRepo)
+	impersonationService := handlers.NewImpersonationService(userRepo, sessionRepo, securityRepo, impersonationConfig)
+	notificationService := handlers.NewNotificationService(notificationRepo)
+	conversationService := handlers.NewConversationService(conversationRepo, userRepo, eventHub)
+	eventService := handlers.NewEventService(eventHub, presence, postRepo, sessionRepo, relationshipRepo, eventConfig)
+	uploadService := handlers.NewUploadService(uploadRepo, blobStore, uploadConfig)
 
 	// Create middleware
-	authMiddleware := middleware.NewAuthMiddleware(sessionRepo, userRepo)
+	authMiddleware := middleware.NewAuthMiddleware(sessionRepo, userRepo, sanctionRepo, securityRepo, impersonationConfig)
 
 	// Create router (using standard net/http for simplicity)
 	mux := http.NewServeMux()
@@ -35,6 +77,109 @@ func SetupRoutes(db *sql.DB) http.Handler {
 	logoutHandler := authMiddleware.RequireAuth(http.HandlerFunc(handlers.LogoutUser(authService)))
 	mux.Handle("/api/auth/logout", logoutHandler)
 
+	// Account routes - current user, data export and deletion
+	mux.Handle("/api/me", authMiddleware.RequireAuth(http.HandlerFunc(handlers.CurrentUser(userService))))
+	mux.Handle("/api/me/export", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ExportUserData(userService))))
+	mux.Handle("/api/me/password", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ChangePassword(userService))))
+	mux.Handle("/api/me/security-events", authMiddleware.RequireAuth(http.HandlerFunc(handlers.SecurityEvents(userService))))
+	mux.Handle("/api/me/email", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ChangeEmail(userService))))
+	mux.Handle("/api/me/profile", authMiddleware.RequireAuth(http.HandlerFunc(handlers.MyProfile(userService))))
+
+	// Public profile routes
+	mux.HandleFunc("/api/users/{username}", handlers.PublicUserProfile(userService))
+	mux.Handle("/api/users", authMiddleware.RequireAuth(http.HandlerFunc(handlers.SuggestUsernames(userService))))
+
+	// Notification routes
+	mux.Handle("/api/notifications", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Notifications(notificationService))))
+	mux.Handle("/api/notifications/unread-count", authMiddleware.RequireAuth(http.HandlerFunc(handlers.UnreadNotificationCount(notificationService))))
+	mux.Handle("/api/notifications/read-all", authMiddleware.RequireAuth(http.HandlerFunc(handlers.MarkAllNotificationsRead(notificationService))))
+	mux.Handle("/api/notifications/preferences", authMiddleware.RequireAuth(http.HandlerFunc(handlers.NotificationPreferences(notificationService))))
+	mux.Handle("/api/notifications/{id}/read", authMiddleware.RequireAuth(http.HandlerFunc(handlers.MarkNotificationRead(notificationService))))
+
+	// Private conversation routes
+	mux.Handle("/api/conversations", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Conversations(conversationService))))
+	mux.Handle("/api/conversations/unread-count", authMiddleware.RequireAuth(http.HandlerFunc(handlers.UnreadMessageCount(conversationService))))
+	mux.Handle("/api/conversations/{id}", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Conversation(conversationService))))
+	mux.Handle("/api/conversations/{id}/messages", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ConversationMessages(conversationService))))
+	mux.Handle("/api/conversations/{id}/read", authMiddleware.RequireAuth(http.HandlerFunc(handlers.MarkConversationRead(conversationService))))
+	mux.Handle("/api/conversations/{id}/mute", authMiddleware.RequireAuth(http.HandlerFunc(handlers.MuteConversation(conversationService))))
+
+	// Real-time updates - Server-Sent Events
+	mux.Handle("/api/events", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Events(eventService))))
+
+	// Block and mute routes
+	mux.Handle("/api/users/{username}/block", authMiddleware.RequireAuth(http.HandlerFunc(handlers.BlockUser(relationshipService))))
+	mux.Handle("/api/users/{username}/mute", authMiddleware.RequireAuth(http.HandlerFunc(handlers.MuteUser(relationshipService))))
+	mux.Handle("/api/me/blocks", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ListBlockedUsers(relationshipService))))
+	mux.Handle("/api/me/mutes", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ListMutedUsers(relationshipService))))
+
+	// Content routes - reading is public, writing requires authentication
+	mux.HandleFunc("/api/categories", handlers.ListCategories(postService))
+	mux.HandleFunc("/api/posts", handlers.Posts(postService))
+	mux.HandleFunc("/api/posts/{id}", handlers.Post(postService))
+	mux.Handle("/api/posts/{id}/comments", authMiddleware.RequireAuth(http.HandlerFunc(handlers.CreateComment(postService))))
+	mux.Handle("/api/posts/{id}/reactions", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ReactToPost(postService))))
+	mux.Handle("/api/comments/{id}/reactions", authMiddleware.RequireAuth(http.HandlerFunc(handlers.ReactToComment(postService))))
+	mux.Handle("/api/comments/{id}", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Comment(postService))))
+	mux.Handle("/api/posts/{id}/bookmark", authMiddleware.RequireAuth(http.HandlerFunc(handlers.BookmarkPost(postService))))
+	mux.Handle("/api/comments/{id}/bookmark", authMiddleware.RequireAuth(http.HandlerFunc(handlers.BookmarkComment(postService))))
+	mux.Handle("/api/me/bookmarks", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Bookmarks(postService))))
+	mux.Handle("/api/me/bookmarks/folders", authMiddleware.RequireAuth(http.HandlerFunc(handlers.BookmarkFolders(postService))))
+	mux.Handle("/api/posts/{id}/live", authMiddleware.RequireAuth(http.HandlerFunc(handlers.LiveThread(eventService))))
+
+	// Draft routes - drafts are only visible to their author
+	mux.Handle("/api/drafts", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Drafts(postService))))
+	mux.Handle("/api/drafts/{id}", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Draft(postService))))
+	mux.Handle("/api/drafts/{id}/publish", authMiddleware.RequireAuth(http.HandlerFunc(handlers.PublishDraft(postService))))
+
+	// Edit history - public, restoring requires moderator or admin role
+	mux.HandleFunc("/api/posts/{id}/revisions", handlers.PostRevisions(postService))
+	mux.HandleFunc("/api/comments/{id}/revisions", handlers.CommentRevisions(postService))
+	mux.Handle("/api/posts/{id}/revisions/{number}/restore", authMiddleware.RequireRole(http.HandlerFunc(handlers.RestorePostRevision(postService)), config.ROLE_MODERATOR, config.ROLE_ADMIN))
+	mux.Handle("/api/comments/{id}/revisions/{number}/restore", authMiddleware.RequireRole(http.HandlerFunc(handlers.RestoreCommentRevision(postService)), config.ROLE_MODERATOR, config.ROLE_ADMIN))
+	mux.Handle("/api/preview", authMiddleware.RequireAuth(http.HandlerFunc(handlers.PreviewContent(postService))))
+	mux.HandleFunc("/api/search", handlers.Search(searchService))
+
+	// Upload routes - uploading requires authentication, stored images are public
+	mux.Handle("/api/uploads", authMiddleware.RequireAuth(http.HandlerFunc(handlers.UploadImage(uploadService))))
+	mux.HandleFunc("/uploads/{id}", handlers.ServeUpload(uploadService))
+	mux.HandleFunc("/uploads/{id}/thumbnail", handlers.ServeThumbnail(uploadService))
+
+	// Signed download URLs of the local storage backend point here
+	if localStore, ok := blobStore.(*storage.LocalStore); ok {
+		mux.HandleFunc("/blobs/{key...}", handlers.ServeBlob(localStore))
+	}
+
+	// Moderation routes - require moderator or admin role
+	mux.Handle("/api/moderation/users/{username}/sanctions", authMiddleware.RequireRole(http.HandlerFunc(handlers.UserSanctions(moderationService)), config.ROLE_MODERATOR, config.ROLE_ADMIN))
+	mux.Handle("/api/moderation/sanctions/{id}", authMiddleware.RequireRole(http.HandlerFunc(handlers.RevokeSanction(moderationService)), config.ROLE_MODERATOR, config.ROLE_ADMIN))
+
+	// Moderation routes - deleted posts and comments
+	mux.Handle("/api/moderation/trash", authMiddleware.RequireRole(http.HandlerFunc(handlers.Trash(moderationService)), config.ROLE_MODERATOR, config.ROLE_ADMIN))
+	mux.Handle("/api/moderation/trash/posts/{id}/restore", authMiddleware.RequireRole(http.HandlerFunc(handlers.RestorePost(moderationService)), config.ROLE_MODERATOR, config.ROLE_ADMIN))
+	mux.Handle("/api/moderation/trash/comments/{id}/restore", authMiddleware.RequireRole(http.HandlerFunc(handlers.RestoreComment(moderationService)), config.ROLE_MODERATOR, config.ROLE_ADMIN))
+
+	// Invite routes - admins and trusted users
+	mux.Handle("/api/invites", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Invites(inviteService))))
+	mux.Handle("/api/invites/{code}", authMiddleware.RequireAuth(http.HandlerFunc(handlers.RevokeInvite(inviteService))))
+
+	// Admin routes - registration approval queue
+	mux.Handle("/api/admin/registrations", authMiddleware.RequireRole(http.HandlerFunc(handlers.PendingRegistrations(adminService)), config.ROLE_ADMIN))
+	mux.Handle("/api/admin/registrations/{id}/approve", authMiddleware.RequireRole(http.HandlerFunc(handlers.ApproveRegistration(adminService)), config.ROLE_ADMIN))
+	mux.Handle("/api/admin/registrations/{id}/reject", authMiddleware.RequireRole(http.HandlerFunc(handlers.RejectRegistration(adminService)), config.ROLE_ADMIN))
+
+	// Admin routes - email domain allow/deny lists
+	mux.Handle("/api/admin/email-domains", authMiddleware.RequireRole(http.HandlerFunc(handlers.EmailDomainRules(adminService)), config.ROLE_ADMIN))
+	mux.Handle("/api/admin/email-domains/{domain}", authMiddleware.RequireRole(http.HandlerFunc(handlers.DeleteEmailDomainRule(adminService)), config.ROLE_ADMIN))
+
+	// Admin routes - grant or revoke the moderator role
+	mux.Handle("/api/admin/users/{username}/role", authMiddleware.RequireRole(http.HandlerFunc(handlers.SetUserRole(adminService)), config.ROLE_ADMIN))
+
+	// Admin routes - view the forum as another user (audited, read-only by default)
+	mux.Handle("/api/admin/users/{username}/impersonate", authMiddleware.RequireRole(http.HandlerFunc(handlers.StartImpersonation(impersonationService)), config.ROLE_ADMIN))
+	mux.Handle("/api/admin/impersonations", authMiddleware.RequireRole(http.HandlerFunc(handlers.ListImpersonations(impersonationService)), config.ROLE_ADMIN))
+	mux.Handle("/api/impersonation", authMiddleware.RequireAuth(http.HandlerFunc(handlers.Impersonation(impersonationService))))
+
 	// Apply the Authenticate middleware to all routes
 	return authMiddleware.Authenticate(mux)
 }
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"time"
+
+	"forum/config"
+)
+
+// BlobStore keeps uploaded files and hands out expiring download URLs for them
+type BlobStore interface {
+	// Put stores data under key, replacing any existing object
+	Put(key string, data []byte, contentType string) error
+
+	// SignedURL returns a download URL for key. The URL can be reused until
+	// the returned time and stays valid for at least the configured TTL after it.
+	SignedURL(key string) (string, time.Time, error)
+}
+
+// NewBlobStore creates the backend selected by the configuration
+func NewBlobStore(cfg config.StorageConfig) (BlobStore, error) {
+	if cfg.URLTTL <= 0 {
+		return nil, fmt.Errorf("STORAGE_URL_TTL must be positive")
+	}
+
+	switch cfg.Backend {
+	case config.STORAGE_LOCAL:
+		// There is no default, so uploads never end up next to the code and
+		// the database in the working directory by accident
+		if cfg.LocalDir == "" {
+			return nil, fmt.Errorf("UPLOAD_DIR must be set to a data directory for the local storage backend")
+		}
+
+		signingKey := []byte(cfg.SigningKey)
+		if len(signingKey) == 0 {
+			log.Println("STORAGE_SIGNING_KEY is not set, download URLs will stop working when the server restarts")
+			signingKey = make([]byte, 32)
+			if _, err := rand.Read(signingKey); err != nil {
+				return nil, err
+			}
+		}
+		return NewLocalStore(cfg.LocalDir, signingKey, cfg.URLTTL), nil
+
+	case config.STORAGE_S3:
+		return NewS3Store(cfg.S3, cfg.URLTTL)
+
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", cfg.Backend)
+	}
+}
+
+// urlWindow places now in a window of length ttl. URLs signed in the same
+// window are identical (so clients and proxies can cache them) and expire a
+// full ttl after the window ends.
+func urlWindow(ttl time.Duration) (start, reuseUntil, expires time.Time) {
+	start = time.Now().UTC().Truncate(ttl)
+	return start, start.Add(ttl), start.Add(2 * ttl)
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/config"
+)
+
+// S3 accepts presigned URLs valid for at most a week
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// S3Store keeps files in an S3-compatible bucket. Requests are signed with
+// AWS Signature Version 4, so it works against AWS and stand-ins such as MinIO.
+type S3Store struct {
+	cfg      config.S3Config
+	endpoint *url.URL
+	ttl      time.Duration
+	client   *http.Client
+}
+
+// NewS3Store creates an S3Store
+func NewS3Store(cfg config.S3Config, ttl time.Duration) (*S3Store, error) {
+	if cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set")
+	}
+	if 2*ttl > maxPresignExpiry {
+		return nil, fmt.Errorf("STORAGE_URL_TTL must be at most %s with S3", maxPresignExpiry/2)
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", endpoint)
+	}
+
+	return &S3Store{
+		cfg:      cfg,
+		endpoint: parsed,
+		ttl:      ttl,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Put uploads an object. Stored files never change, so they are marked as
+// cacheable forever; S3 returns these headers on every download.
+func (s *S3Store) Put(key string, data []byte, contentType string) error {
+	objectURL := s.objectURL(key)
+
+	req, err := http.NewRequest(http.MethodPut, objectURL.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	payloadHash := sha256.Sum256(data)
+	now := time.Now().UTC()
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+
+	signedHeaders := []string{"cache-control", "content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	headerValues := map[string]string{
+		"cache-control":        req.Header.Get("Cache-Control"),
+		"content-type":         contentType,
+		"host":                 objectURL.Host,
+		"x-amz-content-sha256": req.Header.Get("X-Amz-Content-Sha256"),
+		"x-amz-date":           req.Header.Get("X-Amz-Date"),
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headerValues[name]) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodPut,
+		objectURL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := s.scope(now)
+	signature := s.signature(now, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, strings.Join(signedHeaders, ";"), signature))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 PUT %s: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// SignedURL returns a presigned GET URL for key
+func (s *S3Store) SignedURL(key string) (string, time.Time, error) {
+	start, reuseUntil, expires := urlWindow(s.ttl)
+	objectURL := s.objectURL(key)
+	scope := s.scope(start)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.cfg.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", start.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Sub(start).Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		objectURL.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + objectURL.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(start, scope, canonicalRequest))
+	objectURL.RawQuery = canonicalQuery(query)
+
+	return objectURL.String(), reuseUntil, nil
+}
+
+// objectURL returns the URL of an object, path-style or virtual-hosted
+func (s *S3Store) objectURL(key string) *url.URL {
+	objectURL := *s.endpoint
+	escapedKey := escapePath(key)
+
+	basePath := strings.TrimSuffix(s.endpoint.Path, "/")
+	if s.cfg.PathStyle {
+		objectURL.Path = basePath + "/" + s.cfg.Bucket + "/" + key
+		objectURL.RawPath = basePath + "/" + escapePath(s.cfg.Bucket) + "/" + escapedKey
+	} else {
+		objectURL.Host = s.cfg.Bucket + "." + s.endpoint.Host
+		objectURL.Path = basePath + "/" + key
+		objectURL.RawPath = basePath + "/" + escapedKey
+	}
+	return &objectURL
+}
+
+// scope returns the credential scope of a request made at t
+func (s *S3Store) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request with a key derived from the secret
+func (s *S3Store) signature(t time.Time, scope, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format("20060102T150405Z"),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// hmacSHA256 computes HMAC-SHA256 of data
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, escape(name)+"="+escape(query.Get(name)))
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath URI-encodes each segment of a path, keeping the slashes
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape URI-encodes everything but unreserved characters (RFC 3986)
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
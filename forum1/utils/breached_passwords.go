@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// breachedPasswords holds upper-case SHA-1 hex digests of breached passwords
+var breachedPasswords = map[string]struct{}{}
+
+// LoadBreachedPasswords loads a breached password list into memory.
+// Each line is either a plain password or a SHA-1 hex digest, optionally
+// followed by ":count" as in the Have I Been Pwned downloads.
+func LoadBreachedPasswords(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("Error opening breached password list: %v", err)
+	}
+	defer file.Close()
+
+	hashes := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Ignore empty lines and lines starting with #
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if digest, ok := parseSHA1Line(line); ok {
+			hashes[digest] = struct{}{}
+			continue
+		}
+		hashes[sha1Hex(line)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Error reading breached password list: %v", err)
+	}
+
+	breachedPasswords = hashes
+	return nil
+}
+
+// IsBreachedPassword reports whether the password is on the loaded list
+func IsBreachedPassword(password string) bool {
+	_, found := breachedPasswords[sha1Hex(password)]
+	return found
+}
+
+// parseSHA1Line returns the digest of a "HASH" or "HASH:count" line
+func parseSHA1Line(line string) (string, bool) {
+	digest, _, _ := strings.Cut(line, ":")
+	if len(digest) != 40 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(digest), true
+}
+
+// sha1Hex returns the upper-case SHA-1 hex digest of a string
+func sha1Hex(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
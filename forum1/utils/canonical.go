@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldCaser performs full Unicode case folding (e.g. "ß" and "ss" compare equal)
+var foldCaser = cases.Fold()
+
+// NormalizeEmail prepares an email for storage and display: trimmed and lower-cased
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CanonicalEmail returns the form of an email used for uniqueness checks and lookups
+func CanonicalEmail(email string) string {
+	return canonicalize(email)
+}
+
+// CanonicalUsername returns the form of a username used for uniqueness checks and lookups
+func CanonicalUsername(username string) string {
+	return canonicalize(username)
+}
+
+// canonicalize applies NFKC normalisation and Unicode case folding so that
+// visually identical or case-variant strings map to the same key
+func canonicalize(value string) string {
+	value = norm.NFKC.String(strings.TrimSpace(value))
+	return norm.NFKC.String(foldCaser.String(value))
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateContent checks that post or comment content is not blank and not too long
+func ValidateContent(content string, maxLen int) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.New("Content cannot be empty")
+	}
+	if utf8.RuneCountInString(content) > maxLen {
+		return fmt.Errorf("Content must be at most %d characters long", maxLen)
+	}
+
+	return nil
+}
+
+// ValidateDraftContent only checks the length, so unfinished drafts can be
+// saved empty
+func ValidateDraftContent(content string, maxLen int) error {
+	if utf8.RuneCountInString(content) > maxLen {
+		return fmt.Errorf("Content must be at most %d characters long", maxLen)
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+)
+
+// ClientIP returns the remote address of a request without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// DeviceFingerprint identifies the browser a request comes from. It is built
+// from headers that stay stable across sessions, not from the IP address,
+// so a laptop moving between networks is still the same device.
+func DeviceFingerprint(r *http.Request) string {
+	sum := sha256.Sum256([]byte(r.UserAgent() + "\n" + r.Header.Get("Accept-Language")))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"strings"
+
+	"forum/config"
+	"forum/models"
+)
+
+// DiffLines computes a line-level diff turning oldText into newText, based
+// on the longest common subsequence of lines. The common prefix and suffix
+// are trimmed first; if what remains is too large to compare, it is shown as
+// all old lines deleted and all new lines inserted.
+func DiffLines(oldText, newText string) []models.DiffLine {
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+
+	diff := []models.DiffLine{}
+
+	// Common prefix
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		diff = append(diff, models.DiffLine{Op: config.DIFF_EQUAL, Text: oldLines[prefix]})
+		prefix++
+	}
+	oldLines, newLines = oldLines[prefix:], newLines[prefix:]
+
+	// Common suffix, added back at the end
+	suffix := 0
+	for suffix < len(oldLines) && suffix < len(newLines) && oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	common := newLines[len(newLines)-suffix:]
+	oldLines, newLines = oldLines[:len(oldLines)-suffix], newLines[:len(newLines)-suffix]
+
+	if len(oldLines)*len(newLines) > config.MAX_DIFF_CELLS {
+		for _, line := range oldLines {
+			diff = append(diff, models.DiffLine{Op: config.DIFF_DELETE, Text: line})
+		}
+		for _, line := range newLines {
+			diff = append(diff, models.DiffLine{Op: config.DIFF_INSERT, Text: line})
+		}
+	} else {
+		diff = append(diff, lcsDiff(oldLines, newLines)...)
+	}
+
+	for _, line := range common {
+		diff = append(diff, models.DiffLine{Op: config.DIFF_EQUAL, Text: line})
+	}
+
+	return diff
+}
+
+// lcsDiff diffs two line slices with a longest common subsequence table
+func lcsDiff(oldLines, newLines []string) []models.DiffLine {
+	n, m := len(oldLines), len(newLines)
+
+	// lengths[i][j] is the LCS length of oldLines[i:] and newLines[j:]
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	diff := []models.DiffLine{}
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case oldLines[i] == newLines[j]:
+			diff = append(diff, models.DiffLine{Op: config.DIFF_EQUAL, Text: oldLines[i]})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			diff = append(diff, models.DiffLine{Op: config.DIFF_DELETE, Text: oldLines[i]})
+			i++
+		default:
+			diff = append(diff, models.DiffLine{Op: config.DIFF_INSERT, Text: newLines[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		diff = append(diff, models.DiffLine{Op: config.DIFF_DELETE, Text: oldLines[i]})
+	}
+	for ; j < m; j++ {
+		diff = append(diff, models.DiffLine{Op: config.DIFF_INSERT, Text: newLines[j]})
+	}
+
+	return diff
+}
+
+// splitLines splits text into lines, treating CRLF like LF
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
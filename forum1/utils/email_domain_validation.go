@@ -0,0 +1,105 @@
+package utils
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+
+	"forum/config"
+)
+
+//go:embed disposable_domains.txt
+var disposableDomainsList string
+
+// disposableDomains is the bundled list of disposable email providers
+var disposableDomains = parseDomainList(disposableDomainsList)
+
+// emailDomainConfig holds the static rules from the environment
+var emailDomainConfig = config.EmailDomainConfig{BlockDisposable: true}
+
+// ConfigureEmailDomains sets the static email domain rules
+func ConfigureEmailDomains(cfg config.EmailDomainConfig) {
+	emailDomainConfig = cfg
+}
+
+// ValidateEmailDomain checks the domain of an email against the static
+// rules, the rules managed at runtime and the disposable provider list.
+// If any allow rule exists, only allowed domains are accepted.
+func ValidateEmailDomain(email string, allow, deny []string) error {
+	domain := EmailDomain(email)
+	if domain == "" {
+		return errors.New("Invalid email format")
+	}
+
+	allow = append(append([]string{}, emailDomainConfig.Allowlist...), allow...)
+	deny = append(append([]string{}, emailDomainConfig.Denylist...), deny...)
+
+	allowed := matchesDomain(domain, allow)
+	if len(allow) > 0 && !allowed {
+		return errors.New("Registration is restricted to approved email domains")
+	}
+	if matchesDomain(domain, deny) {
+		return errors.New("Email addresses from this domain are not accepted")
+	}
+	if emailDomainConfig.BlockDisposable && !allowed && IsDisposableDomain(domain) {
+		return errors.New("Disposable email addresses are not accepted")
+	}
+
+	return nil
+}
+
+// IsDisposableDomain reports whether a domain, or a parent domain, is a
+// known disposable email provider
+func IsDisposableDomain(domain string) bool {
+	for d := NormalizeDomain(domain); d != ""; {
+		if _, found := disposableDomains[d]; found {
+			return true
+		}
+		_, parent, found := strings.Cut(d, ".")
+		if !found {
+			break
+		}
+		d = parent
+	}
+	return false
+}
+
+// EmailDomain returns the normalised domain part of an email
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return NormalizeDomain(email[at+1:])
+}
+
+// NormalizeDomain lower-cases a domain and strips surrounding dots, spaces and a leading "@"
+func NormalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "@")
+	return strings.Trim(domain, ".")
+}
+
+// matchesDomain reports whether domain equals, or is a subdomain of, one of the rules
+func matchesDomain(domain string, rules []string) bool {
+	for _, rule := range rules {
+		rule = NormalizeDomain(rule)
+		if rule != "" && (domain == rule || strings.HasSuffix(domain, "."+rule)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDomainList reads one domain per line, skipping blanks and # comments
+func parseDomainList(list string) map[string]struct{} {
+	domains := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		line = NormalizeDomain(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[line] = struct{}{}
+	}
+	return domains
+}
@@ -0,0 +1,465 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Errors returned by ProcessImage are safe to show to the uploader
+var (
+	errUnsupportedImage = errors.New("Only JPEG, PNG, GIF and WebP images are supported")
+	errImageTooLarge    = errors.New("Image dimensions are too large")
+	errCorruptImage     = errors.New("Image file is corrupt")
+)
+
+// ProcessedImage is an uploaded image with its metadata stripped and a thumbnail generated
+type ProcessedImage struct {
+	Data          []byte
+	ContentType   string
+	Extension     string
+	SHA256        string
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailType string
+	ThumbnailExt  string
+}
+
+// ProcessImage checks an uploaded file really is a supported image (by its
+// content, never its name), strips EXIF and other metadata and renders a
+// thumbnail whose longest side is thumbSize pixels at most.
+func ProcessImage(data []byte, maxPixels, thumbSize int) (*ProcessedImage, error) {
+	contentType := http.DetectContentType(data)
+
+	// Check the dimensions before decoding or rotating any pixels
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, errImageTooLarge
+	}
+
+	processed := &ProcessedImage{ContentType: contentType}
+	switch contentType {
+	case "image/jpeg":
+		processed.Extension = ".jpg"
+		processed.Data, err = stripJPEGMetadata(data)
+	case "image/png":
+		processed.Extension = ".png"
+		processed.Data, err = stripPNGMetadata(data)
+	case "image/gif":
+		processed.Extension = ".gif"
+		processed.Data, err = stripGIFMetadata(data, maxPixels)
+	case "image/webp":
+		processed.Extension = ".webp"
+		processed.Data, err = stripWebPMetadata(data)
+	default:
+		return nil, errUnsupportedImage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the first frame of animations is decoded
+	img, _, err := image.Decode(bytes.NewReader(processed.Data))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+
+	bounds := img.Bounds()
+	processed.Width, processed.Height = bounds.Dx(), bounds.Dy()
+
+	processed.Thumbnail, processed.ThumbnailType, processed.ThumbnailExt, err = renderThumbnail(img, thumbSize)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(processed.Data)
+	processed.SHA256 = hex.EncodeToString(sum[:])
+
+	return processed, nil
+}
+
+// renderThumbnail scales an image to fit in a size x size box. Opaque images
+// become JPEGs, anything with transparency a PNG.
+func renderThumbnail(img image.Image, size int) ([]byte, string, string, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	thumb := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if thumb.Opaque() {
+		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/jpeg", ".jpg", nil
+	}
+
+	if err := png.Encode(&buf, thumb); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/png", ".png", nil
+}
+
+// stripJPEGMetadata removes EXIF, XMP, IPTC, Multi-Picture and comment
+// segments, and anything after the end of the image, without re-encoding. When EXIF asks for a rotation, the image is rotated and
+// re-encoded instead, since the orientation tag is dropped with the rest.
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errCorruptImage
+	}
+
+	out := []byte{0xFF, 0xD8}
+	orientation := 1
+	i := 2
+	for {
+		if i+2 > len(data) || data[i] != 0xFF {
+			return nil, errCorruptImage
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// Fill byte before a marker
+			i++
+			continue
+		}
+
+		// End of image: anything after it, such as the preview images of
+		// a Multi-Picture file with their own EXIF, is dropped
+		if marker == 0xD9 {
+			out = append(out, 0xFF, 0xD9)
+			break
+		}
+
+		if i+4 > len(data) {
+			return nil, errCorruptImage
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, errCorruptImage
+		}
+		segment := data[i : i+2+length]
+		i += 2 + length
+
+		switch {
+		case marker == 0xE1:
+			if o := exifOrientation(segment[4:]); o != 0 {
+				orientation = o
+			}
+		case marker == 0xE2:
+			// Only the ICC colour profile is kept, not Multi-Picture (MPF) indexes
+			if bytes.HasPrefix(segment[4:], []byte("ICC_PROFILE\x00")) {
+				out = append(out, segment...)
+			}
+		case marker == 0xE0, marker == 0xEE:
+			// JFIF and Adobe colour transform are kept
+			out = append(out, segment...)
+		case marker >= 0xE3 && marker <= 0xEF, marker == 0xFE:
+			// Other application segments and comments are dropped, also
+			// between the scans of a progressive image
+		default:
+			out = append(out, segment...)
+		}
+
+		// Start of scan: the compressed data runs to the next marker that
+		// is not a restart marker or an escaped 0xFF
+		if marker == 0xDA {
+			end := i
+			for {
+				if end+1 >= len(data) {
+					return nil, errCorruptImage
+				}
+				if data[end] == 0xFF && data[end+1] != 0x00 && (data[end+1] < 0xD0 || data[end+1] > 0xD7) {
+					break
+				}
+				end++
+			}
+			out = append(out, data[i:end]...)
+			i = end
+		}
+	}
+
+	if orientation == 1 {
+		return out, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, applyOrientation(img, orientation), &jpeg.Options{Quality: 92}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exifOrientation reads the orientation tag (0x0112) from an APP1 payload,
+// returning 0 when there is none
+func exifOrientation(payload []byte) int {
+	if len(payload) < 14 || string(payload[:6]) != "Exif\x00\x00" {
+		return 0
+	}
+	tiff := payload[6:]
+
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 0
+			}
+			return orientation
+		}
+	}
+	return 0
+}
+
+// applyOrientation rotates and flips a decoded JPEG according to an EXIF
+// orientation value. Decoded JPEGs are opaque, so rows are converted one at
+// a time into a single RGBA copy rather than through a full-size intermediate.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	row := image.NewRGBA(image.Rect(0, 0, w, 1))
+
+	for y := 0; y < h; y++ {
+		draw.Draw(row, row.Bounds(), img, image.Pt(bounds.Min.X, bounds.Min.Y+y), draw.Src)
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], row.Pix[4*x:][:4])
+		}
+	}
+	return dst
+}
+
+// pngKeptChunks are the PNG chunks needed to display an image (including
+// colour information and APNG animation); text, time and EXIF chunks are dropped
+var pngKeptChunks = map[string]bool{
+	"IHDR": true, "PLTE": true, "IDAT": true, "IEND": true, "tRNS": true,
+	"cHRM": true, "gAMA": true, "iCCP": true, "sBIT": true, "sRGB": true,
+	"pHYs": true, "bKGD": true, "acTL": true, "fcTL": true, "fdAT": true,
+}
+
+// stripPNGMetadata removes ancillary PNG chunks that can carry metadata
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if len(data) < len(signature) || string(data[:len(signature)]) != signature {
+		return nil, errCorruptImage
+	}
+
+	out := []byte(signature)
+	for i := len(signature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, errCorruptImage
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) || end < i {
+			return nil, errCorruptImage
+		}
+
+		chunkType := string(data[i+4 : i+8])
+		if pngKeptChunks[chunkType] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out, nil
+}
+
+// stripGIFMetadata removes comment, plain text and application extensions
+// (apart from the animation loop count) and checks the total size of all frames
+func stripGIFMetadata(data []byte, maxPixels int) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, errCorruptImage
+	}
+
+	// Header, logical screen descriptor and global colour table
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (int(data[10]&0x07) + 1)
+	}
+	if i > len(data) {
+		return nil, errCorruptImage
+	}
+	out := append([]byte{}, data[:i]...)
+
+	pixels := 0
+	for {
+		if i >= len(data) {
+			return nil, errCorruptImage
+		}
+
+		switch data[i] {
+		case 0x3B: // Trailer
+			return append(out, 0x3B), nil
+
+		case 0x21: // Extension
+			if i+2 > len(data) {
+				return nil, errCorruptImage
+			}
+			label := data[i+1]
+			end, err := skipGIFSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			keep := label == 0xF9
+			if label == 0xFF && i+14 <= len(data) && data[i+2] == 11 {
+				app := string(data[i+3 : i+14])
+				keep = app == "NETSCAPE2.0" || app == "ANIMEXTS1.0"
+			}
+			if keep {
+				out = append(out, data[i:end]...)
+			}
+			i = end
+
+		case 0x2C: // Image descriptor
+			if i+10 > len(data) {
+				return nil, errCorruptImage
+			}
+			pixels += int(binary.LittleEndian.Uint16(data[i+5:])) * int(binary.LittleEndian.Uint16(data[i+7:]))
+			if pixels > maxPixels {
+				return nil, errImageTooLarge
+			}
+			start := i
+			i += 10
+			if data[start+9]&0x80 != 0 {
+				i += 3 << (int(data[start+9]&0x07) + 1)
+			}
+			// LZW minimum code size, then the image data sub-blocks
+			end, err := skipGIFSubBlocks(data, i+1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, data[start:end]...)
+			i = end
+
+		default:
+			return nil, errCorruptImage
+		}
+	}
+}
+
+// skipGIFSubBlocks returns the offset after the sub-block chain starting at i
+func skipGIFSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errCorruptImage
+		}
+		size := int(data[i])
+		i += 1 + size
+		if size == 0 {
+			return i, nil
+		}
+	}
+}
+
+// stripWebPMetadata removes the EXIF and XMP chunks of a WebP file and
+// clears the matching flags in its VP8X header
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errCorruptImage
+	}
+
+	out := append([]byte{}, data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errCorruptImage
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if size < 0 || end > len(data) || end < i {
+			// Some encoders omit the padding byte of the last chunk
+			if end == len(data)+1 {
+				end = len(data)
+			} else {
+				return nil, errCorruptImage
+			}
+		}
+
+		switch fourCC := string(data[i : i+4]); fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x0C // EXIF and XMP flags
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"forum/config"
+)
+
+// mailConfig holds the SMTP settings used by SendMail
+var mailConfig = config.MailConfig{}
+
+// ConfigureMail sets the SMTP settings used by SendMail
+func ConfigureMail(cfg config.MailConfig) {
+	mailConfig = cfg
+}
+
+// SiteURL returns the public URL of the forum for links in emails
+func SiteURL() string {
+	return strings.TrimRight(mailConfig.SiteURL, "/")
+}
+
+// SendMail sends a plain text email. When no SMTP host is configured the
+// message is logged, which is enough for development.
+func SendMail(to, subject, body string) error {
+	// Refuse header injection through the recipient or subject
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	if mailConfig.Host == "" {
+		log.Printf("Email to %s: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	msg := "From: " + mailConfig.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+
+	var auth smtp.Auth
+	if mailConfig.Username != "" {
+		auth = smtp.PlainAuth("", mailConfig.Username, mailConfig.Password, mailConfig.Host)
+	}
+
+	addr := net.JoinHostPort(mailConfig.Host, strconv.Itoa(mailConfig.Port))
+	return smtp.SendMail(addr, auth, mailConfig.From, []string{to}, []byte(msg))
+}
+
+// SendMailAsync sends an email in the background and logs failures, so the
+// time spent talking to the mail server does not show in response times
+func SendMailAsync(to, subject, body string) {
+	go func() {
+		if err := SendMail(to, subject, body); err != nil {
+			log.Printf("Failed to send email to %s: %v", to, err)
+		}
+	}()
+}
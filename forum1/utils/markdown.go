@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown converts CommonMark with tables, strikethrough, autolinks and
+// @mentions.
+// Raw HTML in the source is dropped by goldmark's default (safe) renderer.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		&mentionExtension{},
+	),
+)
+
+// htmlPolicy is the strict allowlist every rendered document passes through
+var htmlPolicy = newHTMLPolicy()
+
+// newHTMLPolicy allows the elements Markdown produces and nothing else
+func newHTMLPolicy() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+
+	policy.AllowElements(
+		"p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+		"blockquote", "ul", "ol", "li", "strong", "em", "del", "pre", "code",
+		"table", "thead", "tbody", "tr", "th", "td",
+	)
+	policy.AllowAttrs("start").Matching(regexp.MustCompile(`^[0-9]+$`)).OnElements("ol")
+	policy.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+
+	// Fenced code blocks keep their language class for client-side highlighting
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[A-Za-z0-9_+#.-]{1,32}$`)).OnElements("code")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^mention$`)).OnElements("a")
+
+	// Links and images only to http(s) (and mailto for links)
+	policy.AllowAttrs("href", "title").OnElements("a")
+	policy.AllowAttrs("src", "alt", "title").OnElements("img")
+	policy.AllowURLSchemes("http", "https", "mailto")
+	policy.AllowRelativeURLs(true)
+	policy.RequireParseableURLs(true)
+	policy.RequireNoFollowOnLinks(true)
+	policy.RequireNoReferrerOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return policy
+}
+
+// RenderMarkdown converts Markdown to sanitised HTML, linking @mentions of
+// the mentioned usernames to their profiles. Content that fails to convert is
+// returned escaped, as plain text.
+func RenderMarkdown(source string, mentioned []string) string {
+	var buf bytes.Buffer
+	if err := renderWithMentions(source, mentioned, &buf); err != nil {
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return htmlPolicy.Sanitize(buf.String())
+}
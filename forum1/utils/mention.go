@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// kindMention is the AST node kind of an @mention
+var kindMention = ast.NewNodeKind("Mention")
+
+// mentionNode is an @mention of a user
+type mentionNode struct {
+	ast.BaseInline
+	Username string // as registered, which may differ in case from the text
+	Written  []byte // as written, including the @
+}
+
+// Kind implements ast.Node
+func (n *mentionNode) Kind() ast.NodeKind {
+	return kindMention
+}
+
+// Dump implements ast.Node
+func (n *mentionNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Username": n.Username}, nil)
+}
+
+// mentionContextKey holds a document's *mentionState in the parser context
+var mentionContextKey = parser.NewContextKey()
+
+// mentionState holds the users, by canonical username, whose mentions are
+// parsed. Without any, every well-formed mention is, to find the users a
+// document mentions.
+type mentionState struct {
+	known map[string]string
+}
+
+// mentionParser recognises @username. The username follows the rules of
+// ValidateUsername and the @ must not follow a word character, so email
+// addresses are not mentions. Mentions in code are never seen, as code
+// spans and blocks are parsed first.
+type mentionParser struct{}
+
+// Trigger implements parser.InlineParser
+func (p *mentionParser) Trigger() []byte {
+	return []byte{'@'}
+}
+
+// Parse implements parser.InlineParser
+func (p *mentionParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	state, ok := pc.Get(mentionContextKey).(*mentionState)
+	if !ok || isUsernameByte(block.PrecendingCharacter()) {
+		return nil
+	}
+
+	line, _ := block.PeekLine()
+	end := 1
+	for end < len(line) && isUsernameByte(rune(line[end])) {
+		end++
+	}
+	username := string(line[1:end])
+	if ValidateUsername(username) != nil {
+		return nil
+	}
+
+	registered := username
+	if state.known != nil {
+		var known bool
+		if registered, known = state.known[CanonicalUsername(username)]; !known {
+			return nil
+		}
+	}
+
+	block.Advance(end)
+	return &mentionNode{Username: registered, Written: []byte("@" + username)}
+}
+
+// isUsernameByte reports whether r may appear in a username
+func isUsernameByte(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// mentionRenderer renders mentions as links to the user's profile
+type mentionRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer
+func (r *mentionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMention, r.render)
+}
+
+// render writes a mention. Inside a link, where another link is not
+// allowed, it stays plain text.
+func (r *mentionRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	mention := node.(*mentionNode)
+
+	if inLink(node) {
+		w.Write(util.EscapeHTML(mention.Written))
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString(`<a href="/api/users/`)
+	w.WriteString(mention.Username)
+	w.WriteString(`" class="mention">`)
+	w.Write(util.EscapeHTML(mention.Written))
+	w.WriteString("</a>")
+	return ast.WalkContinue, nil
+}
+
+// inLink reports whether a node is part of a link's text
+func inLink(node ast.Node) bool {
+	for ancestor := node.Parent(); ancestor != nil; ancestor = ancestor.Parent() {
+		if ancestor.Kind() == ast.KindLink || ancestor.Kind() == ast.KindAutoLink {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionExtension adds @mentions to a goldmark Markdown
+type mentionExtension struct{}
+
+// Extend implements goldmark.Extender
+func (e *mentionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&mentionParser{}, 500)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&mentionRenderer{}, 500)))
+}
+
+// ParseMentions returns the usernames mentioned in Markdown source, each once,
+// in the order they first appear. Mentions in code or in the text of a link
+// do not count. Whether the users exist is not checked.
+func ParseMentions(source string) []string {
+	context := parser.NewContext()
+	context.Set(mentionContextKey, &mentionState{})
+	document := markdown.Parser().Parse(text.NewReader([]byte(source)), parser.WithContext(context))
+
+	usernames := []string{}
+	seen := map[string]bool{}
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		mention, ok := node.(*mentionNode)
+		if !entering || !ok || inLink(node) {
+			return ast.WalkContinue, nil
+		}
+		if canonical := CanonicalUsername(mention.Username); !seen[canonical] {
+			seen[canonical] = true
+			usernames = append(usernames, mention.Username)
+		}
+		return ast.WalkContinue, nil
+	})
+	return usernames
+}
+
+// renderWithMentions converts Markdown to HTML, linking the mentions of the
+// given users
+func renderWithMentions(source string, mentioned []string, buf *bytes.Buffer) error {
+	state := &mentionState{known: map[string]string{}}
+	for _, username := range mentioned {
+		state.known[CanonicalUsername(username)] = username
+	}
+	context := parser.NewContext()
+	context.Set(mentionContextKey, state)
+
+	return markdown.Convert([]byte(source), buf, parser.WithContext(context))
+}
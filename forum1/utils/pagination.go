@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+
+	"forum/config"
+)
+
+// ParsePagination reads the "page" (1-based) and "limit" query parameters
+// and returns the matching LIMIT and OFFSET values
+func ParsePagination(r *http.Request) (limit, offset int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = config.DEFAULT_PAGE_SIZE
+	}
+	if limit > config.MAX_PAGE_SIZE {
+		limit = config.MAX_PAGE_SIZE
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	return limit, (page - 1) * limit
+}
@@ -1,17 +1,184 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"forum/config"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword creates a bcrypt hash of the password
+// hashConfig holds the parameters used for newly created hashes
+var hashConfig = config.DefaultHashConfig()
+
+var errInvalidHash = errors.New("invalid password hash format")
+
+// dummyHash is compared against when a login names an unknown account, so
+// that it takes as long as a login with a wrong password
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// hashSlots bounds how many password hashes are computed at once, so a burst
+// of logins cannot exhaust memory
+var hashSlots = make(chan struct{}, hashConfig.MaxConcurrent)
+
+// acquireHashSlot waits for a free hashing slot and returns the function that
+// releases it
+func acquireHashSlot() func() {
+	slots := hashSlots
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// argon2Params are the parameters encoded in an argon2id PHC string
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// ConfigurePasswordHashing sets the algorithm and parameters for new hashes
+func ConfigurePasswordHashing(cfg config.HashConfig) {
+	hashConfig = cfg
+	hashSlots = make(chan struct{}, cfg.MaxConcurrent)
+	dummyHashOnce = sync.Once{}
+}
+
+// DummyPasswordCheck spends the same work as CheckPasswordHash on a hash
+// created with the current configuration. It always reports false.
+func DummyPasswordCheck(password string) bool {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword(GenerateUUID())
+	})
+	CheckPasswordHash(password, dummyHash)
+	return false
+}
+
+// HashPassword hashes the password with the configured algorithm.
+// Argon2id hashes are stored in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+	defer acquireHashSlot()()
+
+	if hashConfig.Algorithm == config.HASH_ALGORITHM_BCRYPT {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), hashConfig.BcryptCost)
+		return string(bytes), err
+	}
+
+	salt := make([]byte, hashConfig.Argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hashConfig.Argon2Time, hashConfig.Argon2Memory, hashConfig.Argon2Threads, hashConfig.Argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		hashConfig.Argon2Memory,
+		hashConfig.Argon2Time,
+		hashConfig.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
-// CheckPasswordHash compares a bcrypt hashed password with a plain password
+// CheckPasswordHash compares a bcrypt or argon2id hashed password with a plain password
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	defer acquireHashSlot()()
+
+	if isBcryptHash(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		return err == nil
+	}
+
+	params, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1
+}
+
+// NeedsRehash reports whether a stored hash was created with a different
+// algorithm or parameters than the current configuration, whether stronger
+// or weaker
+func NeedsRehash(hash string) bool {
+	if isBcryptHash(hash) {
+		if hashConfig.Algorithm != config.HASH_ALGORITHM_BCRYPT {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != hashConfig.BcryptCost
+	}
+
+	if hashConfig.Algorithm != config.HASH_ALGORITHM_ARGON2ID {
+		return true
+	}
+
+	params, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.memory != hashConfig.Argon2Memory ||
+		params.time != hashConfig.Argon2Time ||
+		params.threads != hashConfig.Argon2Threads ||
+		uint32(len(params.key)) != hashConfig.Argon2KeyLen ||
+		uint32(len(params.salt)) != hashConfig.Argon2SaltLen
+}
+
+// isBcryptHash checks for the $2a$, $2b$ or $2y$ bcrypt prefixes
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// decodeArgon2Hash parses an argon2id PHC string
+func decodeArgon2Hash(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errInvalidHash
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, errInvalidHash
+	}
+	// argon2 needs at least one pass, one lane and 8 KiB of memory per lane;
+	// more than the configurable maximum is not run
+	if params.time == 0 || params.threads == 0 || params.memory < 8*uint32(params.threads) {
+		return nil, errInvalidHash
+	}
+	if params.time > config.MAX_ARGON2_TIME || params.memory > config.MAX_ARGON2_MEMORY_KIB {
+		return nil, errInvalidHash
+	}
+
+	var err error
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, errInvalidHash
+	}
+
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(params.key) == 0 {
+		return nil, errInvalidHash
+	}
+
+	return &params, nil
 }
@@ -2,14 +2,32 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"forum/config"
+	"math"
 	"regexp"
+	"strings"
+	"unicode/utf8"
 )
 
-func ValidatePassword(password string) error {
-	// Password validation
-	if len(password) < config.MIN_PASSWORD_LEN || len(password) > config.MAX_PASSWORD_LEN {
-		return errors.New("Password must be at least 8 characters long")
+// passwordPolicy holds the rules enforced by ValidatePassword
+var passwordPolicy = config.DefaultPasswordPolicy()
+
+// ConfigurePasswordPolicy sets the rules enforced by ValidatePassword
+func ConfigurePasswordPolicy(policy config.PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// ValidatePassword checks a password against the configured policy.
+// The username and email are used to reject passwords built from them.
+func ValidatePassword(password, username, email string) error {
+	// Password length validation (counted in characters so passphrases work)
+	length := utf8.RuneCountInString(password)
+	if length < passwordPolicy.MinLength || length > passwordPolicy.MaxLength {
+		return fmt.Errorf("Password must be between %d and %d characters long", passwordPolicy.MinLength, passwordPolicy.MaxLength)
+	}
+	if passwordPolicy.MaxBytes > 0 && len(password) > passwordPolicy.MaxBytes {
+		return fmt.Errorf("Password must be at most %d bytes long; accented letters and symbols take more than one", passwordPolicy.MaxBytes)
 	}
 
 	// Check password complexity
@@ -18,9 +36,74 @@ func ValidatePassword(password string) error {
 	hasNumber := regexp.MustCompile(`[0-9]`).MatchString(password)
 	hasSpecial := regexp.MustCompile(`[^a-zA-Z0-9]`).MatchString(password)
 
-	if !(hasUppercase && hasLowercase && hasNumber && hasSpecial) {
+	if (passwordPolicy.RequireUppercase && !hasUppercase) ||
+		(passwordPolicy.RequireLowercase && !hasLowercase) ||
+		(passwordPolicy.RequireNumber && !hasNumber) ||
+		(passwordPolicy.RequireSpecial && !hasSpecial) {
 		return errors.New("Password must contain uppercase, lowercase, number, and special character")
 	}
 
+	// Check estimated strength
+	if passwordPolicy.MinEntropyBits > 0 && passwordEntropy(password, hasUppercase, hasLowercase, hasNumber, hasSpecial) < float64(passwordPolicy.MinEntropyBits) {
+		return errors.New("Password is too easy to guess, use a longer or more varied password")
+	}
+
+	// Check banned substrings
+	lowered := strings.ToLower(password)
+	banned := append([]string{}, passwordPolicy.BannedSubstrings...)
+	if passwordPolicy.BanPersonalDetails {
+		banned = append(banned, username)
+		if at := strings.Index(email, "@"); at > 0 {
+			banned = append(banned, email[:at])
+		}
+	}
+	for _, substring := range banned {
+		substring = strings.ToLower(strings.TrimSpace(substring))
+		if len(substring) >= 3 && strings.Contains(lowered, substring) {
+			return errors.New("Password must not contain your username, email or other easily guessed words")
+		}
+	}
+
+	// Check the offline breached password list
+	if IsBreachedPassword(password) {
+		return errors.New("Password has appeared in a data breach, please choose a different one")
+	}
+
 	return nil
 }
+
+// passwordEntropy estimates the strength of a password in bits from its
+// length and the size of the character pool it draws from
+func passwordEntropy(password string, hasUppercase, hasLowercase, hasNumber, hasSpecial bool) float64 {
+	pool := 0
+	if hasUppercase {
+		pool += 26
+	}
+	if hasLowercase {
+		pool += 26
+	}
+	if hasNumber {
+		pool += 10
+	}
+	if hasSpecial {
+		pool += 33
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	// Repeated characters add little strength, so count each distinct one once
+	// and every repetition at half weight
+	seen := make(map[rune]bool)
+	effective := 0.0
+	for _, c := range password {
+		if seen[c] {
+			effective += 0.5
+		} else {
+			seen[c] = true
+			effective++
+		}
+	}
+
+	return effective * math.Log2(float64(pool))
+}
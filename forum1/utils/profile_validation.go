@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"forum/config"
+	"forum/models"
+)
+
+// ValidateProfile checks the lengths of profile fields and that links are http(s) URLs
+func ValidateProfile(profile models.UserProfile) error {
+	if utf8.RuneCountInString(profile.DisplayName) > config.MAX_DISPLAY_NAME_LEN {
+		return fmt.Errorf("Display name must be at most %d characters long", config.MAX_DISPLAY_NAME_LEN)
+	}
+	if utf8.RuneCountInString(profile.Bio) > config.MAX_BIO_LEN {
+		return fmt.Errorf("Bio must be at most %d characters long", config.MAX_BIO_LEN)
+	}
+	if utf8.RuneCountInString(profile.Location) > config.MAX_LOCATION_LEN {
+		return fmt.Errorf("Location must be at most %d characters long", config.MAX_LOCATION_LEN)
+	}
+	if err := validateProfileURL(profile.Website); err != nil {
+		return fmt.Errorf("Website %v", err)
+	}
+	if err := validateProfileURL(profile.AvatarURL); err != nil {
+		return fmt.Errorf("Avatar URL %v", err)
+	}
+
+	return nil
+}
+
+// validateProfileURL allows empty values or absolute http(s) URLs
+func validateProfileURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > config.MAX_PROFILE_URL_LEN {
+		return fmt.Errorf("must be at most %d characters long", config.MAX_PROFILE_URL_LEN)
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errors.New("must be a valid http or https URL")
+	}
+
+	return nil
+}
+
+// NormalizeProfile trims surrounding whitespace from every profile field
+func NormalizeProfile(profile *models.UserProfile) {
+	profile.DisplayName = strings.TrimSpace(profile.DisplayName)
+	profile.Bio = strings.TrimSpace(profile.Bio)
+	profile.Location = strings.TrimSpace(profile.Location)
+	profile.Website = strings.TrimSpace(profile.Website)
+	profile.AvatarURL = strings.TrimSpace(profile.AvatarURL)
+}
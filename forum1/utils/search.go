@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"html"
+	"strings"
+)
+
+// Markers wrapped around matches by the FTS5 snippet() function. Control
+// characters cannot come from users' text, so they survive HTML escaping
+// unambiguously.
+const (
+	SnippetMatchStart = "\x02"
+	SnippetMatchEnd   = "\x03"
+)
+
+// BuildSearchQuery turns free text into an FTS5 query matching every term.
+// Terms are quoted so FTS5 operators in user input are taken literally; a
+// trailing "*" still makes a prefix search. It returns "" when nothing is left.
+func BuildSearchQuery(text string) string {
+	var terms []string
+	for _, field := range strings.Fields(text) {
+		prefix := strings.HasSuffix(field, "*")
+		term := strings.Trim(strings.ReplaceAll(field, `"`, ""), "*")
+		if term == "" {
+			continue
+		}
+
+		quoted := `"` + term + `"`
+		if prefix {
+			quoted += "*"
+		}
+		terms = append(terms, quoted)
+	}
+	return strings.Join(terms, " ")
+}
+
+// HighlightSnippet escapes a snippet for HTML and turns the match markers into <mark> tags
+func HighlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, SnippetMatchStart, "<mark>")
+	return strings.ReplaceAll(snippet, SnippetMatchEnd, "</mark>")
+}
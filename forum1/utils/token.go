@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/base32"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,3 +23,12 @@ func GenerateSessionToken() string {
 func CalculateSessionExpiry() time.Time {
 	return time.Now().Add(24 * time.Hour)
 }
+
+// GenerateInviteCode creates a short, human friendly random invite code
+func GenerateInviteCode() (string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes), nil
+}
@@ -2,14 +2,23 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"forum/config"
 	"regexp"
 )
 
+// reservedUsernames holds the canonical forms of usernames nobody may register
+var reservedUsernames = canonicalSet(config.LoadReservedUsernames())
+
+// ConfigureReservedUsernames replaces the list of reserved usernames
+func ConfigureReservedUsernames(usernames []string) {
+	reservedUsernames = canonicalSet(usernames)
+}
+
 func ValidateUsername(username string) error {
 	// Username validation
 	if len(username) < config.MIN_USERNAME_LEN || len(username) > config.MAX_USERNAME_LEN {
-		return errors.New("Username must be between 3 and 30 characters long")
+		return fmt.Errorf("Username must be between %d and %d characters long", config.MIN_USERNAME_LEN, config.MAX_USERNAME_LEN)
 
 	}
 
@@ -19,5 +28,37 @@ func ValidateUsername(username string) error {
 		return errors.New("Username can only contain alphanumeric characters and underscores")
 	}
 
+	// Reserved usernames are compared case-insensitively
+	if IsReservedUsername(username) {
+		return errors.New("Username is reserved")
+	}
+
+	return nil
+}
+
+// ValidateUsernamePrefix checks the start of a username typed to complete a mention
+func ValidateUsernamePrefix(prefix string) error {
+	if prefix == "" || len(prefix) > config.MAX_USERNAME_LEN {
+		return fmt.Errorf("Prefix must be between 1 and %d characters long", config.MAX_USERNAME_LEN)
+	}
+	if !regexp.MustCompile("^[a-zA-Z0-9_]+$").MatchString(prefix) {
+		return errors.New("Prefix can only contain alphanumeric characters and underscores")
+	}
+
 	return nil
 }
+
+// IsReservedUsername reports whether a username matches a reserved one in any case
+func IsReservedUsername(username string) bool {
+	_, reserved := reservedUsernames[CanonicalUsername(username)]
+	return reserved
+}
+
+// canonicalSet builds a lookup set of canonical usernames
+func canonicalSet(usernames []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		set[CanonicalUsername(username)] = struct{}{}
+	}
+	return set
+}
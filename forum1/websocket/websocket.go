@@ -0,0 +1,316 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// acceptGUID is appended to the client's key to prove the server speaks
+// WebSocket (RFC 6455, section 1.3)
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// writeTimeout bounds how long a write to a stalled client may block
+const writeTimeout = 10 * time.Second
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close codes used by the server (RFC 6455, section 7.4.1)
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseTryAgainLater   = 1013
+)
+
+// ErrClosed is returned by ReadMessage once the connection is closed
+var ErrClosed = errors.New("websocket: connection closed")
+
+var (
+	errProtocol = errors.New("websocket: protocol error")
+	errTooBig   = errors.New("websocket: message too big")
+)
+
+// Conn is the server side of a WebSocket connection. It supports text
+// messages, answers pings and completes the closing handshake. Reads must
+// come from one goroutine; writes are safe from any.
+type Conn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	maxMessage  int
+	idleTimeout time.Duration
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// Upgrade completes the opening handshake of a WebSocket request. Messages
+// larger than maxMessage bytes are refused, and the connection is dropped
+// when nothing, not even a pong, arrives for idleTimeout. On failure the
+// error response has already been written.
+func Upgrade(w http.ResponseWriter, r *http.Request, maxMessage int, idleTimeout time.Duration) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response cannot be hijacked")
+	}
+	netConn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// The server's deadlines no longer apply once the connection is ours
+	netConn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := netConn.Write([]byte(response)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return &Conn{
+		conn:        netConn,
+		reader:      buffered.Reader,
+		maxMessage:  maxMessage,
+		idleTimeout: idleTimeout,
+	}, nil
+}
+
+// acceptKey computes Sec-WebSocket-Accept for a client's Sec-WebSocket-Key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether a comma separated header lists token
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text message from the client. Pings are
+// answered and pongs skipped along the way. When the client closes the
+// connection, or breaks the protocol, the connection is closed and ErrClosed
+// or the cause is returned.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	inMessage := false
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			switch err {
+			case errProtocol:
+				c.Close(CloseProtocolError, "")
+			case errTooBig:
+				c.Close(CloseMessageTooBig, "")
+			default:
+				c.Close(CloseGoingAway, "")
+			}
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+
+		case opPong:
+
+		case opClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.Close(code, "")
+			return nil, ErrClosed
+
+		case opBinary:
+			c.Close(CloseUnsupportedData, "Only text messages are supported")
+			return nil, errors.New("websocket: binary message")
+
+		case opText, opContinuation:
+			if (opcode == opText) == inMessage {
+				c.Close(CloseProtocolError, "")
+				return nil, errProtocol
+			}
+			inMessage = true
+
+			if len(message)+len(payload) > c.maxMessage {
+				c.Close(CloseMessageTooBig, "")
+				return nil, errTooBig
+			}
+			message = append(message, payload...)
+
+			if fin {
+				if !utf8.Valid(message) {
+					c.Close(CloseInvalidPayload, "")
+					return nil, errors.New("websocket: invalid UTF-8")
+				}
+				return message, nil
+			}
+
+		default:
+			c.Close(CloseProtocolError, "")
+			return nil, errProtocol
+		}
+	}
+}
+
+// readFrame reads and unmasks one frame
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	if c.idleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	}
+
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	// No extensions are negotiated, and clients must mask every frame
+	if header[0]&0x70 != 0 || !masked {
+		err = errProtocol
+		return
+	}
+
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	// Control frames are short and never fragmented
+	if opcode >= opClose && (length > 125 || !fin) {
+		err = errProtocol
+		return
+	}
+	if length > uint64(c.maxMessage) {
+		err = errTooBig
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return
+}
+
+// WriteText sends a text message
+func (c *Conn) WriteText(message []byte) error {
+	return c.writeFrame(opText, message)
+}
+
+// Ping sends a ping; browsers answer it without involving the page
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// writeFrame writes one unfragmented, unmasked frame
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame with code and reason, then closes the
+// connection. Only the first call has an effect.
+func (c *Conn) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		if len(reason) > 123 {
+			reason = reason[:123]
+		}
+		c.writeFrame(opClose, append(payload, reason...))
+		c.conn.Close()
+	})
+}